@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// anomalyMinGroup is the smallest group of values (including the bundle's own
+// value) that apshuffle will use to decide whether a value is unusual.
+const anomalyMinGroup = 3
+
+// An anomaly describes how unusual a bundle's value is. Each score is a robust
+// z-score, or NaN if there wasn't enough data to compute it.
+type anomaly struct {
+	// peer compares the value to those of the other bundles from the same
+	// revision, captured in the same window of time.
+	peer float64
+	// history compares the value to those of the earlier bundles from the
+	// same process.
+	history float64
+}
+
+// score is whichever of the two comparisons is farther from zero, or NaN if
+// neither is available. It keeps its sign: a bundle can be unusual for having
+// a value that's much smaller than expected, as well as much larger.
+func (a *anomaly) score() float64 {
+	switch {
+	case math.IsNaN(a.peer):
+		return a.history
+	case math.IsNaN(a.history):
+		return a.peer
+	}
+	if math.Abs(a.history) > math.Abs(a.peer) {
+		return a.history
+	}
+	return a.peer
+}
+
+func anomalyScores(values map[*meta]*big.Int, window time.Duration) map[*meta]*anomaly {
+	type peerKey struct {
+		revision string
+		window   time.Time
+	}
+	peers := make(map[peerKey][]float64)
+	procs := make(map[string][]*meta)
+	vals := make(map[*meta]float64, len(values))
+	for m, v := range values {
+		f, _ := new(big.Float).SetInt(v).Float64()
+		vals[m] = f
+		k := peerKey{revision: m.Revision, window: m.CaptureTime.Truncate(window)}
+		peers[k] = append(peers[k], f)
+		procs[m.ProcID] = append(procs[m.ProcID], m)
+	}
+
+	scores := make(map[*meta]*anomaly, len(values))
+	for m, f := range vals {
+		k := peerKey{revision: m.Revision, window: m.CaptureTime.Truncate(window)}
+		scores[m] = &anomaly{peer: robustZ(f, peers[k]), history: math.NaN()}
+	}
+	for _, ms := range procs {
+		sort.Slice(ms, func(i, j int) bool { return ms[i].CaptureTime.Before(ms[j].CaptureTime) })
+		var history []float64
+		for _, m := range ms {
+			history = append(history, vals[m])
+			scores[m].history = robustZ(vals[m], history)
+		}
+	}
+	return scores
+}
+
+// anomalySort orders the bundles from most to least unusual, in either
+// direction, followed by the bundles that couldn't be scored.
+func anomalySort(scores map[*meta]*anomaly) []*meta {
+	var sorted []*meta
+	for m := range scores {
+		sorted = append(sorted, m)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		si, sj := math.Abs(scores[sorted[i]].score()), math.Abs(scores[sorted[j]].score())
+		if ni, nj := math.IsNaN(si), math.IsNaN(sj); ni != nj {
+			return nj
+		}
+		if si != sj && !math.IsNaN(si) {
+			return si > sj
+		}
+
+		// tiebreak
+		if !sorted[i].CaptureTime.Equal(sorted[j].CaptureTime) {
+			return sorted[i].CaptureTime.Before(sorted[j].CaptureTime)
+		}
+		return sorted[i].ProcID < sorted[j].ProcID
+	})
+	return sorted
+}
+
+// robustZ describes how far x is from the median of the group, in units
+// that match a standard deviation for normally-distributed values. It uses the
+// median absolute deviation (MAD) so that a few extreme values in the group
+// don't hide each other. When more than half of the group has the same value,
+// the MAD is zero; then it uses the mean absolute deviation instead.
+//
+// The group should include x. It returns NaN if the group is too small.
+func robustZ(x float64, group []float64) float64 {
+	if len(group) < anomalyMinGroup {
+		return math.NaN()
+	}
+	med := median(group)
+	dev := make([]float64, len(group))
+	var sumDev float64
+	for i, v := range group {
+		dev[i] = math.Abs(v - med)
+		sumDev += dev[i]
+	}
+	if x == med {
+		return 0
+	}
+	if mad := median(dev); mad != 0 {
+		return 0.6745 * (x - med) / mad
+	}
+	// The group includes x, so the mean absolute deviation isn't zero.
+	meanAD := sumDev / float64(len(group))
+	return (x - med) / (1.2533 * meanAD)
+}
+
+func median(vals []float64) float64 {
+	sorted := append([]float64(nil), vals...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
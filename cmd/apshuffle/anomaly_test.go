@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAnomalySort(t *testing.T) {
+	nan := math.NaN()
+	scores := make(map[*meta]*anomaly)
+	add := func(dir string, peer, history, score float64) {
+		m := &meta{dir: dir, ProcID: dir, CaptureTime: time.Unix(0, 0)}
+		a := &anomaly{peer: peer, history: history}
+		scores[m] = a
+		if have := a.score(); have != score && !(math.IsNaN(have) && math.IsNaN(score)) {
+			t.Errorf("score of %q = %v, want %v", dir, have, score)
+		}
+	}
+	// Values that are far below what's expected are as unusual as those that
+	// are far above.
+	add("unscored", nan, nan, nan)
+	add("normal", 0.5, -0.2, 0.5)
+	add("high-peer", 4, 0.1, 4)
+	add("low-peer", -10, 0.3, -10)
+	add("high-history", nan, 6, 6)
+	add("low-history", 1, -5, -5)
+
+	var have []string
+	for _, m := range anomalySort(scores) {
+		have = append(have, m.dir)
+	}
+	want := []string{"low-peer", "high-history", "low-history", "high-peer", "normal", "unscored"}
+	if !slices.Equal(have, want) {
+		t.Errorf("anomalySort = %q, want %q", have, want)
+	}
+}
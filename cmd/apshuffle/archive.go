@@ -0,0 +1,525 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archiveSuffixes lists the kinds of files that archiveFS presents as
+// directories.
+var archiveSuffixes = []string{".tar", ".tar.gz", ".tgz", ".zip"}
+
+// archiveSmallFile is the size of the largest file that archiveFS keeps in
+// memory after indexing an archive. That covers the bundles' metadata, so
+// reading it doesn't require decompressing the archive again.
+const archiveSmallFile = 4 << 10
+
+// archiveCacheSize is the number of groups of files from tar archives that
+// archiveFS keeps in memory at once. A group is the files in one archive that
+// have the same base name, such as each bundle's "pprof/goroutine". The
+// workers tend to read the bundles in order, so they all work from the same
+// few groups at any moment.
+const archiveCacheSize = 8
+
+// cleanArchiveName converts the name of a file within an archive to the form
+// that fs.ValidPath expects.
+func cleanArchiveName(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "./"))
+}
+
+func isArchiveName(name string) bool {
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// An archiveFS presents a directory tree where each tar, gzip-compressed tar,
+// and zip file appears as a directory that holds the archive's contents. So
+// when "bundles.tar.gz" holds "host1/2024-01-01/meta", archiveFS has a file
+// at "bundles.tar.gz/host1/2024-01-01/meta".
+type archiveFS struct {
+	root fs.FS
+
+	mu       sync.Mutex
+	archives map[string]*archive
+	recent   []archiveGroup // groups of files in memory, oldest first
+}
+
+type archiveGroup struct {
+	arc  *archive
+	base string
+}
+
+func newArchiveFS(root fs.FS) *archiveFS {
+	return &archiveFS{root: root, archives: make(map[string]*archive)}
+}
+
+type archive struct {
+	name string
+	info fs.FileInfo
+
+	once    sync.Once
+	err     error
+	entries map[string]*archiveEntry // by path within the archive, including "."
+
+	loadMu sync.Mutex
+	groups map[string]map[string][]byte // contents of a tar archive's files, by base name and then path
+}
+
+// An archiveEntry describes a file or directory within an archive. It serves
+// as both its fs.FileInfo and its fs.DirEntry.
+type archiveEntry struct {
+	name     string
+	size     int64
+	mode     fs.FileMode
+	modTime  time.Time
+	children []*archiveEntry
+
+	kept bool   // whether data holds the file's contents
+	data []byte // for small files
+}
+
+func (e *archiveEntry) Name() string               { return e.name }
+func (e *archiveEntry) Size() int64                { return e.size }
+func (e *archiveEntry) Mode() fs.FileMode          { return e.mode }
+func (e *archiveEntry) ModTime() time.Time         { return e.modTime }
+func (e *archiveEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *archiveEntry) Sys() any                   { return nil }
+func (e *archiveEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *archiveEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+// locate finds the archive that holds name, and name's path within it. It
+// returns a nil archive when name is outside of all archives.
+func (a *archiveFS) locate(op, name string) (*archive, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) && name[i] != '/' {
+			continue
+		}
+		prefix := name[:i]
+		if !isArchiveName(prefix) {
+			continue
+		}
+		info, err := fs.Stat(a.root, prefix)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		a.mu.Lock()
+		arc, ok := a.archives[prefix]
+		if !ok {
+			arc = &archive{name: prefix, info: info}
+			a.archives[prefix] = arc
+		}
+		a.mu.Unlock()
+
+		arc.once.Do(func() { arc.err = a.index(arc) })
+		if arc.err != nil {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: arc.err}
+		}
+
+		inner := "."
+		if i < len(name) {
+			inner = name[i+1:]
+		}
+		return arc, inner, nil
+	}
+	return nil, name, nil
+}
+
+// inArchive reports whether name is within an archive.
+func (a *archiveFS) inArchive(name string) bool {
+	arc, _, _ := a.locate("stat", name)
+	return arc != nil
+}
+
+func (a *archiveFS) entry(op string, arc *archive, inner string) (*archiveEntry, error) {
+	e, ok := arc.entries[inner]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: path.Join(arc.name, inner), Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	arc, inner, err := a.locate("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if arc == nil {
+		f, err := a.root.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		if dir, ok := f.(fs.ReadDirFile); ok {
+			return &rootDir{ReadDirFile: dir}, nil
+		}
+		return f, nil
+	}
+	e, err := a.entry("open", arc, inner)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsDir() {
+		return &archiveDir{entry: e}, nil
+	}
+	buf, err := a.contents(arc, inner, e)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &archiveFile{entry: e, Reader: bytes.NewReader(buf)}, nil
+}
+
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	arc, inner, err := a.locate("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if arc != nil {
+		e, err := a.entry("readdir", arc, inner)
+		if err != nil {
+			return nil, err
+		}
+		var ents []fs.DirEntry
+		for _, child := range e.children {
+			ents = append(ents, child)
+		}
+		return ents, nil
+	}
+
+	ents, err := fs.ReadDir(a.root, name)
+	showArchives(ents)
+	return ents, err
+}
+
+// showArchives updates a directory listing from outside of the archives so
+// the archives appear as directories.
+func showArchives(ents []fs.DirEntry) {
+	for i, ent := range ents {
+		if !ent.Type().IsRegular() || !isArchiveName(ent.Name()) {
+			continue
+		}
+		info, err := ent.Info()
+		if err != nil {
+			continue
+		}
+		ents[i] = &archiveEntry{name: ent.Name(), mode: fs.ModeDir | 0555, modTime: info.ModTime()}
+	}
+}
+
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	arc, inner, err := a.locate("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if arc == nil {
+		return fs.Stat(a.root, name)
+	}
+	return a.entry("stat", arc, inner)
+}
+
+func (a *archiveFS) ReadFile(name string) ([]byte, error) {
+	arc, inner, err := a.locate("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if arc == nil {
+		return fs.ReadFile(a.root, name)
+	}
+	e, err := a.entry("read", arc, inner)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	buf, err := a.contents(arc, inner, e)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return bytes.Clone(buf), nil
+}
+
+// index lists the files in the archive, and keeps the contents of the small
+// ones.
+func (a *archiveFS) index(arc *archive) error {
+	arc.entries = map[string]*archiveEntry{
+		".": {name: path.Base(arc.name), mode: fs.ModeDir | 0555, modTime: arc.info.ModTime()},
+	}
+	add := func(name string, info fs.FileInfo, read func() ([]byte, error)) error {
+		name = cleanArchiveName(name)
+		if !fs.ValidPath(name) || name == "." {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		e, ok := arc.entries[name]
+		if !ok {
+			e = &archiveEntry{name: path.Base(name)}
+			arc.entries[name] = e
+			for child, dir := e, path.Dir(name); ; dir = path.Dir(dir) {
+				parent, ok := arc.entries[dir]
+				if !ok {
+					parent = &archiveEntry{name: path.Base(dir), mode: fs.ModeDir | 0555, modTime: arc.info.ModTime()}
+					arc.entries[dir] = parent
+				}
+				parent.children = append(parent.children, child)
+				if ok || dir == "." {
+					break
+				}
+				child = parent
+			}
+		}
+		e.size, e.mode, e.modTime = info.Size(), info.Mode(), info.ModTime()
+		e.kept, e.data = false, nil
+		if info.IsDir() {
+			e.size, e.mode = 0, fs.ModeDir|0555
+			return nil
+		}
+		if info.Size() <= archiveSmallFile {
+			buf, err := read()
+			if err != nil {
+				return err
+			}
+			e.kept, e.data = true, buf
+		}
+		return nil
+	}
+
+	if strings.HasSuffix(arc.name, ".zip") {
+		err := a.readZip(arc, func(zr *zip.Reader) error {
+			for _, zf := range zr.File {
+				err := add(zf.Name, zf.FileInfo(), func() ([]byte, error) {
+					rc, err := zf.Open()
+					if err != nil {
+						return nil, err
+					}
+					defer rc.Close()
+					return io.ReadAll(rc)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		err := a.readTar(arc, func(hdr *tar.Header, r io.Reader) error {
+			return add(hdr.Name, hdr.FileInfo(), func() ([]byte, error) { return io.ReadAll(r) })
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range arc.entries {
+		sort.Slice(e.children, func(i, j int) bool { return e.children[i].name < e.children[j].name })
+	}
+	return nil
+}
+
+// readZip calls fn with the contents of the zip archive. The archive's file
+// is only open during the call, so a tree with many zip archives doesn't
+// exhaust the process's file descriptors.
+func (a *archiveFS) readZip(arc *archive, fn func(zr *zip.Reader) error) error {
+	f, err := a.root.Open(arc.name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		buf, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		ra = bytes.NewReader(buf)
+	}
+	zr, err := zip.NewReader(ra, arc.info.Size())
+	if err != nil {
+		return fmt.Errorf("zip.NewReader: %w", err)
+	}
+	return fn(zr)
+}
+
+// readTar calls fn for each entry of the tar archive, in order.
+func (a *archiveFS) readTar(arc *archive, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := a.root.Open(arc.name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(arc.name, ".gz") || strings.HasSuffix(arc.name, ".tgz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("gzip.NewReader: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tar.Reader: %w", err)
+		}
+		err = fn(hdr, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// contents returns the contents of the regular file at inner within the
+// archive, which e describes. The caller must not modify the result.
+func (a *archiveFS) contents(arc *archive, inner string, e *archiveEntry) ([]byte, error) {
+	if e.kept {
+		return e.data, nil
+	}
+
+	if strings.HasSuffix(arc.name, ".zip") {
+		var buf []byte
+		err := a.readZip(arc, func(zr *zip.Reader) error {
+			var err error
+			buf, err = fs.ReadFile(zr, inner)
+			return err
+		})
+		return buf, err
+	}
+
+	// Reading a file from a tar archive means decompressing everything
+	// before it. A caller that asks for one bundle's "pprof/goroutine" is
+	// likely to ask for the others soon, so load them all in one pass.
+	base := path.Base(inner)
+	arc.loadMu.Lock()
+	group, ok := arc.groups[base]
+	loaded := false
+	if !ok {
+		group = make(map[string][]byte)
+		err := a.readTar(arc, func(hdr *tar.Header, r io.Reader) error {
+			name := cleanArchiveName(hdr.Name)
+			if !hdr.FileInfo().Mode().IsRegular() || path.Base(name) != base {
+				return nil
+			}
+			buf, err := io.ReadAll(r)
+			group[name] = buf
+			return err
+		})
+		if err != nil {
+			arc.loadMu.Unlock()
+			return nil, err
+		}
+		if arc.groups == nil {
+			arc.groups = make(map[string]map[string][]byte)
+		}
+		arc.groups[base] = group
+		loaded = true
+	}
+	buf, ok := group[inner]
+	arc.loadMu.Unlock()
+
+	// Only the goroutine that loaded the group counts it as recent, so each
+	// group appears in the list at most once.
+	if loaded {
+		a.remember(archiveGroup{arc: arc, base: base})
+	}
+
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return buf, nil
+}
+
+// remember records that the group of files is in memory, and releases the
+// groups that have been there the longest.
+func (a *archiveFS) remember(g archiveGroup) {
+	a.mu.Lock()
+	a.recent = append(a.recent, g)
+	var evict []archiveGroup
+	for len(a.recent) > archiveCacheSize {
+		evict = append(evict, a.recent[0])
+		a.recent = a.recent[1:]
+	}
+	a.mu.Unlock()
+
+	// An archive's loadMu may be held for the whole time it takes to
+	// decompress the archive, so don't wait for it while holding a.mu.
+	for _, g := range evict {
+		g.arc.loadMu.Lock()
+		delete(g.arc.groups, g.base)
+		g.arc.loadMu.Unlock()
+	}
+}
+
+// A rootDir is a directory outside of the archives.
+type rootDir struct {
+	fs.ReadDirFile
+}
+
+func (d *rootDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	ents, err := d.ReadDirFile.ReadDir(n)
+	showArchives(ents)
+	return ents, err
+}
+
+type archiveFile struct {
+	entry *archiveEntry
+	*bytes.Reader
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *archiveFile) Close() error               { return nil }
+
+type archiveDir struct {
+	entry  *archiveEntry
+	offset int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.entry, nil }
+func (d *archiveDir) Close() error               { return nil }
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entry.children[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(rest) > n {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	ents := make([]fs.DirEntry, len(rest))
+	for i, e := range rest {
+		ents[i] = e
+	}
+	return ents, nil
+}
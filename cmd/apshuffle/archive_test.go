@@ -0,0 +1,293 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testArchiveFiles are the contents of each test archive. Some names start
+// with "./", as they do in archives made with "tar -C dir .", and most of the
+// directories only appear as the parents of files.
+var testArchiveFiles = []struct {
+	name string
+	body string
+}{
+	{"./host1/b1/meta", "host1 b1 meta"},
+	{"./host1/b1/pprof/goroutine", strings.Repeat("goroutine host1 b1\n", 1000)},
+	{"host1/b2/meta", "host1 b2 meta"},
+	{"host1/b2/pprof/goroutine", strings.Repeat("goroutine host1 b2\n", 1000)},
+	{"host2/b1/meta", ""},
+	{"host2/b1/pprof/trace", strings.Repeat("trace host2 b1\n", 1000)},
+}
+
+var testArchiveTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func buildTar(t *testing.T, compress bool) []byte {
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(zw)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+
+	// One directory has its own entry.
+	err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "./host1/", Mode: 0755, ModTime: testArchiveTime})
+	if err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	for _, f := range testArchiveFiles {
+		err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: 0644, Size: int64(len(f.body)), ModTime: testArchiveTime})
+		if err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		_, err = tw.Write([]byte(f.body))
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	// Links and other special files don't appear in the archiveFS.
+	err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "host1/b2/_link", Linkname: "../b1", ModTime: testArchiveTime})
+	if err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar.Writer.Close: %v", err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatalf("gzip.Writer.Close: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range testArchiveFiles {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: testArchiveTime})
+		if err != nil {
+			t.Fatalf("CreateHeader: %v", err)
+		}
+		_, err = w.Write([]byte(f.body))
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip.Writer.Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func testArchiveRoot(t *testing.T) fstest.MapFS {
+	return fstest.MapFS{
+		"plain/meta":          {Data: []byte("plain meta"), ModTime: testArchiveTime},
+		"a.tar":               {Data: buildTar(t, false), ModTime: testArchiveTime},
+		"b.tar.gz":            {Data: buildTar(t, true), ModTime: testArchiveTime},
+		"sub/c.tgz":           {Data: buildTar(t, true), ModTime: testArchiveTime},
+		"d.zip":               {Data: buildZip(t), ModTime: testArchiveTime},
+		"notes.txt":           {Data: []byte("not an archive"), ModTime: testArchiveTime},
+		"broken.tar.gz":       {Data: []byte("not gzip"), ModTime: testArchiveTime},
+		"dir.zip/inside/meta": {Data: []byte("a directory with an archive's name"), ModTime: testArchiveTime},
+	}
+}
+
+var testArchives = []string{"a.tar", "b.tar.gz", "sub/c.tgz", "d.zip"}
+
+func TestArchiveFS(t *testing.T) {
+	afs := newArchiveFS(testArchiveRoot(t))
+
+	for _, arc := range testArchives {
+		t.Run(arc, func(t *testing.T) {
+			info, err := fs.Stat(afs, arc)
+			if err != nil {
+				t.Fatalf("Stat(%q): %v", arc, err)
+			}
+			if !info.IsDir() || info.Name() != path.Base(arc) {
+				t.Errorf("Stat(%q) = %q, dir=%t; want directory %q", arc, info.Name(), info.IsDir(), path.Base(arc))
+			}
+
+			readDir := func(name string, want ...string) {
+				t.Helper()
+				ents, err := fs.ReadDir(afs, path.Join(arc, name))
+				if err != nil {
+					t.Fatalf("ReadDir(%q): %v", name, err)
+				}
+				var have []string
+				for _, ent := range ents {
+					n := ent.Name()
+					if ent.IsDir() {
+						n += "/"
+					}
+					have = append(have, n)
+				}
+				if !reflect.DeepEqual(have, want) {
+					t.Errorf("ReadDir(%q) = %q, want %q", name, have, want)
+				}
+			}
+			readDir(".", "host1/", "host2/")
+			readDir("host1", "b1/", "b2/")
+			readDir("host1/b2", "meta", "pprof/")
+			readDir("host2/b1/pprof", "trace")
+
+			for _, f := range testArchiveFiles {
+				name := path.Join(arc, cleanArchiveName(f.name))
+				buf, err := fs.ReadFile(afs, name)
+				if err != nil {
+					t.Errorf("ReadFile(%q): %v", name, err)
+					continue
+				}
+				if string(buf) != f.body {
+					t.Errorf("ReadFile(%q) = %d bytes, want %d", name, len(buf), len(f.body))
+				}
+				info, err := fs.Stat(afs, name)
+				if err != nil {
+					t.Errorf("Stat(%q): %v", name, err)
+					continue
+				}
+				if info.IsDir() || info.Size() != int64(len(f.body)) || !info.ModTime().Equal(testArchiveTime) {
+					t.Errorf("Stat(%q) = dir=%t size=%d time=%v", name, info.IsDir(), info.Size(), info.ModTime())
+				}
+			}
+
+			for _, name := range []string{"host1/b3", "host1/b1/pprof/heap", "host1/b2/_link"} {
+				_, err := fs.Stat(afs, path.Join(arc, name))
+				if !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("Stat(%q); err = %v, want ErrNotExist", name, err)
+				}
+			}
+			// Archives may hold names like this, but it's not a valid path.
+			if _, err := fs.Stat(afs, arc+"/./host1"); !errors.Is(err, fs.ErrInvalid) {
+				t.Errorf("Stat(%q); err = %v, want ErrInvalid", arc+"/./host1", err)
+			}
+			if _, err := fs.ReadFile(afs, path.Join(arc, "host1")); err == nil {
+				t.Errorf("ReadFile of a directory; err = nil")
+			}
+		})
+	}
+
+	// Files outside of archives, and files that only have the names of
+	// archives, are as they are in the underlying FS.
+	if buf, err := fs.ReadFile(afs, "plain/meta"); err != nil || string(buf) != "plain meta" {
+		t.Errorf("ReadFile(plain/meta) = %q, %v", buf, err)
+	}
+	if buf, err := fs.ReadFile(afs, "dir.zip/inside/meta"); err != nil || !strings.HasPrefix(string(buf), "a directory") {
+		t.Errorf("ReadFile(dir.zip/inside/meta) = %q, %v", buf, err)
+	}
+	if _, err := fs.ReadDir(afs, "broken.tar.gz"); err == nil {
+		t.Errorf("ReadDir(broken.tar.gz); err = nil")
+	}
+
+	if afs.inArchive("plain/meta") || !afs.inArchive("a.tar/host1/b1/meta") {
+		t.Errorf("inArchive is wrong")
+	}
+}
+
+func TestArchiveFSTestFS(t *testing.T) {
+	root := testArchiveRoot(t)
+	delete(root, "broken.tar.gz")
+	afs := newArchiveFS(root)
+
+	var expected []string
+	for _, arc := range testArchives {
+		for _, f := range testArchiveFiles {
+			expected = append(expected, path.Join(arc, cleanArchiveName(f.name)))
+		}
+	}
+	expected = append(expected, "plain/meta", "notes.txt", "dir.zip/inside/meta")
+	if err := fstest.TestFS(afs, expected...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveFSSmallFiles(t *testing.T) {
+	afs := newArchiveFS(testArchiveRoot(t))
+
+	// Reading the metadata doesn't decompress the archive again.
+	for _, arc := range []string{"a.tar", "b.tar.gz"} {
+		_, err := fs.ReadFile(afs, arc+"/host1/b1/meta")
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if groups := afs.archives[arc].groups; len(groups) != 0 {
+			t.Errorf("%s holds %d groups of files after reading small file", arc, len(groups))
+		}
+	}
+
+	// Reading a larger file loads the others with the same name, but not
+	// the rest of the archive.
+	_, err := fs.ReadFile(afs, "a.tar/host1/b1/pprof/goroutine")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	groups := afs.archives["a.tar"].groups
+	if len(groups) != 1 || len(groups["goroutine"]) != 2 {
+		t.Errorf("a.tar holds groups %v, want two goroutine profiles", groups)
+	}
+}
+
+func TestArchiveFSEviction(t *testing.T) {
+	// Use more groups than archiveFS keeps in memory, and read them all
+	// concurrently.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	var names []string
+	for i := range archiveCacheSize * 2 {
+		name := fmt.Sprintf("b/profile%d", i)
+		body := strings.Repeat(name, archiveSmallFile)
+		err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(body))})
+		if err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		tw.Write([]byte(body))
+		names = append(names, name)
+	}
+	tw.Close()
+	afs := newArchiveFS(fstest.MapFS{"x.tar": {Data: buf.Bytes()}})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		for _, name := range names {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				buf, err := fs.ReadFile(afs, "x.tar/"+name)
+				if err != nil {
+					t.Errorf("ReadFile(%q): %v", name, err)
+					return
+				}
+				if want := strings.Repeat(name, archiveSmallFile); string(buf) != want {
+					t.Errorf("ReadFile(%q) has the wrong contents", name)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	afs.mu.Lock()
+	recent := len(afs.recent)
+	afs.mu.Unlock()
+	if recent > archiveCacheSize {
+		t.Errorf("%d groups in memory, want at most %d", recent, archiveCacheSize)
+	}
+	if have := len(afs.archives["x.tar"].groups); have > archiveCacheSize {
+		t.Errorf("archive holds %d groups, want at most %d", have, archiveCacheSize)
+	}
+}
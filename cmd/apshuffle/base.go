@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"sort"
+)
+
+// baseStats lists the ways that -vs-base can summarize the values of the
+// baseline bundles. Each function receives the values in increasing order.
+var baseStats = map[string]func(sorted []*big.Int) *big.Int{
+	"median": func(sorted []*big.Int) *big.Int {
+		n := len(sorted)
+		if n%2 == 1 {
+			return sorted[n/2]
+		}
+		sum := new(big.Int).Add(sorted[n/2-1], sorted[n/2])
+		return sum.Quo(sum, big.NewInt(2))
+	},
+	"mean": func(sorted []*big.Int) *big.Int {
+		sum := new(big.Int)
+		for _, v := range sorted {
+			sum.Add(sum, v)
+		}
+		return sum.Quo(sum, big.NewInt(int64(len(sorted))))
+	},
+	"min": func(sorted []*big.Int) *big.Int { return sorted[0] },
+	"max": func(sorted []*big.Int) *big.Int { return sorted[len(sorted)-1] },
+}
+
+// baseValue summarizes the values of the baseline bundles.
+func baseValue(values map[*meta]*big.Int, stat func(sorted []*big.Int) *big.Int) *big.Int {
+	var sorted []*big.Int
+	for _, v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	return stat(sorted)
+}
+
+// A baseDiff compares a bundle's value to the baseline.
+type baseDiff struct {
+	value *big.Int
+	base  *big.Int
+	delta *big.Int
+	// ratio is value divided by base, or NaN if base is zero.
+	ratio float64
+}
+
+func diffVsBase(values map[*meta]*big.Int, base *big.Int) map[*meta]*baseDiff {
+	fb, _ := new(big.Float).SetInt(base).Float64()
+	diffs := make(map[*meta]*baseDiff, len(values))
+	for m, v := range values {
+		d := &baseDiff{value: v, base: base, delta: new(big.Int).Sub(v, base), ratio: math.NaN()}
+		if fb != 0 {
+			fv, _ := new(big.Float).SetInt(v).Float64()
+			d.ratio = fv / fb
+		}
+		diffs[m] = d
+	}
+	return diffs
+}
+
+// ratioSort orders the bundles from the largest to the smallest ratio of their
+// value to the baseline. When the baseline is zero, the ratios are undefined,
+// and the order is as for profileSort.
+func ratioSort(diffs map[*meta]*baseDiff) []*meta {
+	values := make(map[*meta]*big.Int, len(diffs))
+	for m, d := range diffs {
+		values[m] = d.delta
+	}
+	sorted := profileSort(values)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return diffs[sorted[i]].ratio > diffs[sorted[j]].ratio
+	})
+	return sorted
+}
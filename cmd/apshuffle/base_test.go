@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBaseValue(t *testing.T) {
+	values := make(map[*meta]*big.Int)
+	for i, v := range []int64{9, 1, 4, 10} {
+		values[&meta{ProcID: string(rune('a' + i))}] = big.NewInt(v)
+	}
+	odd := map[*meta]*big.Int{{}: big.NewInt(5), {}: big.NewInt(1), {}: big.NewInt(3)}
+	one := map[*meta]*big.Int{{}: big.NewInt(7)}
+
+	for _, tt := range []struct {
+		stat   string
+		values map[*meta]*big.Int
+		want   int64
+	}{
+		{"median", values, 6}, // (4+9)/2, rounded down
+		{"median", odd, 3},
+		{"median", one, 7},
+		{"mean", values, 6}, // 24/4
+		{"mean", odd, 3},
+		{"mean", one, 7},
+		{"min", values, 1},
+		{"max", values, 10},
+		{"min", one, 7},
+		{"max", one, 7},
+	} {
+		if have := baseValue(tt.values, baseStats[tt.stat]); have.Int64() != tt.want {
+			t.Errorf("baseValue %s of %d values = %v, expected %d", tt.stat, len(tt.values), have, tt.want)
+		}
+	}
+}
+
+func TestDiffVsBase(t *testing.T) {
+	a, b := &meta{dir: "a"}, &meta{dir: "b"}
+	values := map[*meta]*big.Int{a: big.NewInt(30), b: big.NewInt(5)}
+
+	diffs := diffVsBase(values, big.NewInt(10))
+	if d := diffs[a]; d.delta.Int64() != 20 || d.base.Int64() != 10 || d.ratio != 3 {
+		t.Errorf("diff of 30 vs 10 = delta %v base %v ratio %v", d.delta, d.base, d.ratio)
+	}
+	if d := diffs[b]; d.delta.Int64() != -5 || d.ratio != 0.5 {
+		t.Errorf("diff of 5 vs 10 = delta %v ratio %v", d.delta, d.ratio)
+	}
+
+	// With a base of zero, the ratio is undefined, but the delta is not.
+	diffs = diffVsBase(values, big.NewInt(0))
+	for m, v := range values {
+		d := diffs[m]
+		if d.delta.Cmp(v) != 0 || !math.IsNaN(d.ratio) {
+			t.Errorf("diff of %v vs 0 = delta %v ratio %v, expected delta %v ratio NaN", v, d.delta, d.ratio, v)
+		}
+		if finite(d.ratio) != nil {
+			t.Errorf("finite(NaN) != nil")
+		}
+	}
+}
+
+func TestRatioSort(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	values := make(map[*meta]*big.Int)
+	add := func(dir string, v int64, capture time.Duration) {
+		values[&meta{dir: dir, ProcID: dir, CaptureTime: t0.Add(capture)}] = big.NewInt(v)
+	}
+	add("double", 20, 0)
+	add("half", 5, 0)
+	add("same", 10, 0)
+	add("triple", 30, 0)
+	// Equal ratios keep the order of profileSort, which puts the later
+	// capture first.
+	add("double-later", 20, time.Minute)
+	add("double-earlier", 20, -time.Minute)
+
+	dirs := func(ms []*meta) []string {
+		var out []string
+		for _, m := range ms {
+			out = append(out, m.dir)
+		}
+		return out
+	}
+
+	have := dirs(ratioSort(diffVsBase(values, big.NewInt(10))))
+	want := []string{"triple", "double-later", "double", "double-earlier", "same", "half"}
+	if !slices.Equal(have, want) {
+		t.Errorf("ratioSort = %q, expected %q", have, want)
+	}
+
+	// When the base is zero, none of the ratios are defined, so the order is
+	// by delta, as for profileSort.
+	have = dirs(ratioSort(diffVsBase(values, big.NewInt(0))))
+	want = dirs(profileSort(values))
+	if !slices.Equal(have, want) {
+		t.Errorf("ratioSort with zero base = %q, expected %q", have, want)
+	}
+	if want[0] != "triple" || want[len(want)-1] != "half" {
+		t.Errorf("profileSort = %q, expected largest to smallest", want)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheVersion changes when the format of the cache file changes, or when
+// the way apshuffle computes the values it holds changes.
+const cacheVersion = 2
+
+// A bundleCache remembers what apshuffle learned from each file in previous
+// runs, so it can skip reading and parsing them again. Each entry is valid for
+// as long as the file's size and modification time are unchanged.
+//
+// A nil *bundleCache is valid, and remembers nothing.
+type bundleCache struct {
+	name string
+
+	mu      sync.Mutex
+	dirty   bool
+	Version int
+	Files   map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	Size    int64
+	ModTime time.Time
+
+	// Meta holds the contents of a "meta" file.
+	Meta *meta `json:",omitempty"`
+	// Profile describes a file in a bundle's "pprof" directory.
+	Profile *profileTotals `json:",omitempty"`
+}
+
+// profileTotals describes the sum of each sample type's values in a profile,
+// after filtering the samples.
+type profileTotals struct {
+	// Invalid is set when the file isn't a profile.
+	Invalid           bool `json:",omitempty"`
+	SampleTypes       []string
+	DefaultSampleType string
+	// Totals maps a description of a set of sample filters (see sampleFilter.key)
+	// to the sum of each sample type's values after applying those filters.
+	Totals map[string][]*big.Int
+}
+
+// defaultCachePath returns the cache file to use for the bundles in dir,
+// within the user's cache directory.
+func defaultCachePath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(base, "go-tracing-toolbox", "apshuffle", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// loadCache reads the cache file, if it exists. An empty name disables the
+// cache.
+func loadCache(name string) (*bundleCache, error) {
+	if name == "" {
+		return nil, nil
+	}
+	c := &bundleCache{name: name}
+	buf, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		// The cache is only advisory. If it's been damaged, start over.
+		if json.Unmarshal(buf, c) != nil {
+			c = &bundleCache{name: name}
+		}
+	}
+	if c.Version != cacheVersion || c.Files == nil {
+		c.Version = cacheVersion
+		c.Files = make(map[string]*cacheEntry)
+	}
+	return c, nil
+}
+
+// lookup returns the cache entry for the file, or nil if the cache has no
+// entry for the file in its current state.
+func (c *bundleCache) lookup(file string, info fs.FileInfo) *cacheEntry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.Files[file]
+	if e == nil || e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	return e
+}
+
+// update calls fn with the file's cache entry, creating a new one if the file
+// has changed.
+func (c *bundleCache) update(file string, info fs.FileInfo, fn func(e *cacheEntry)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.Files[file]
+	if e == nil || e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) {
+		e = &cacheEntry{Size: info.Size(), ModTime: info.ModTime()}
+		c.Files[file] = e
+	}
+	fn(e)
+	c.dirty = true
+}
+
+// save writes the cache file, if its contents changed. It forgets about any
+// files that aren't listed in keep.
+func (c *bundleCache) save(keep []string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	have := make(map[string]bool, len(keep))
+	for _, file := range keep {
+		have[file] = true
+	}
+	for file := range c.Files {
+		if !have[file] {
+			delete(c.Files, file)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.name), 0777)
+	if err != nil {
+		return err
+	}
+	tmp := c.name + ".tmp"
+	err = os.WriteFile(tmp, buf, 0666)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, c.name)
+	if err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// forEach calls fn for each integer in [0, n), using up to workers goroutines.
+func forEach(n int, workers int, fn func(i int)) {
+	workers = max(1, min(workers, n))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
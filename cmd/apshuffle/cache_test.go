@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "cache", "apshuffle.json")
+
+	var files []string
+	stat := func(file string) fs.FileInfo {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Stat; err = %v", err)
+		}
+		return info
+	}
+	mtime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, base := range []string{"a", "b", "c"} {
+		file := filepath.Join(dir, base)
+		err := os.WriteFile(file, []byte("meta "+base), 0666)
+		if err != nil {
+			t.Fatalf("WriteFile; err = %v", err)
+		}
+		err = os.Chtimes(file, mtime, mtime)
+		if err != nil {
+			t.Fatalf("Chtimes; err = %v", err)
+		}
+		files = append(files, file)
+	}
+
+	c, err := loadCache(name)
+	if err != nil {
+		t.Fatalf("loadCache; err = %v", err)
+	}
+	for _, file := range files {
+		if e := c.lookup(file, stat(file)); e != nil {
+			t.Errorf("lookup(%q) in new cache = %v, expected nil", file, e)
+		}
+		c.update(file, stat(file), func(e *cacheEntry) {
+			e.Meta = &meta{Hostname: filepath.Base(file)}
+		})
+	}
+	err = c.save(files)
+	if err != nil {
+		t.Fatalf("save; err = %v", err)
+	}
+
+	// Change the size of the first file and the modification time of the
+	// second. Only the third file's entry is still valid.
+	err = os.WriteFile(files[0], []byte("longer meta a"), 0666)
+	if err != nil {
+		t.Fatalf("WriteFile; err = %v", err)
+	}
+	err = os.Chtimes(files[0], mtime, mtime)
+	if err != nil {
+		t.Fatalf("Chtimes; err = %v", err)
+	}
+	err = os.Chtimes(files[1], mtime, mtime.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Chtimes; err = %v", err)
+	}
+
+	c, err = loadCache(name)
+	if err != nil {
+		t.Fatalf("loadCache; err = %v", err)
+	}
+	if e := c.lookup(files[0], stat(files[0])); e != nil {
+		t.Errorf("lookup after size change = %v, expected nil", e)
+	}
+	if e := c.lookup(files[1], stat(files[1])); e != nil {
+		t.Errorf("lookup after mtime change = %v, expected nil", e)
+	}
+	if e := c.lookup(files[2], stat(files[2])); e == nil || e.Meta == nil || e.Meta.Hostname != "c" {
+		t.Errorf("lookup of unchanged file = %v, expected its entry", e)
+	}
+
+	// An update of a changed file starts over with a new entry.
+	c.update(files[0], stat(files[0]), func(e *cacheEntry) {
+		if e.Meta != nil {
+			t.Errorf("update after size change has old Meta %v", e.Meta)
+		}
+	})
+
+	// Saving forgets the files that aren't in the keep list.
+	err = c.save(files[2:])
+	if err != nil {
+		t.Fatalf("save; err = %v", err)
+	}
+	c, err = loadCache(name)
+	if err != nil {
+		t.Fatalf("loadCache; err = %v", err)
+	}
+	if len(c.Files) != 1 || c.Files[files[2]] == nil {
+		t.Errorf("after save(keep), cache has %d files, expected only %q", len(c.Files), files[2])
+	}
+}
+
+func TestCacheDamaged(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		body string
+	}{
+		{"corrupt", `{"Version":2,"Files":{"a":`},
+		{"old version", `{"Version":1,"Files":{"a":{"Size":1}}}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, tt.name+".json")
+			err := os.WriteFile(name, []byte(tt.body), 0666)
+			if err != nil {
+				t.Fatalf("WriteFile; err = %v", err)
+			}
+			c, err := loadCache(name)
+			if err != nil {
+				t.Fatalf("loadCache; err = %v", err)
+			}
+			if c.Version != cacheVersion || len(c.Files) != 0 {
+				t.Errorf("loadCache = version %d with %d files, expected an empty version %d cache",
+					c.Version, len(c.Files), cacheVersion)
+			}
+		})
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	c, err := loadCache("")
+	if err != nil || c != nil {
+		t.Fatalf("loadCache(\"\") = %v, %v; expected nil, nil", c, err)
+	}
+	info, err := os.Stat(t.TempDir())
+	if err != nil {
+		t.Fatalf("Stat; err = %v", err)
+	}
+	if e := c.lookup("x", info); e != nil {
+		t.Errorf("lookup in nil cache = %v", e)
+	}
+	c.update("x", info, func(e *cacheEntry) { t.Errorf("update in nil cache called fn") })
+	if err := c.save(nil); err != nil {
+		t.Errorf("save of nil cache; err = %v", err)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	const n = 50
+	for _, workers := range []int{0, 1, 4, n + 10} {
+		var counts [n]atomic.Int32
+		forEach(n, workers, func(i int) { counts[i].Add(1) })
+		for i := range counts {
+			if have := counts[i].Load(); have != 1 {
+				t.Errorf("forEach with %d workers visited %d %d times", workers, i, have)
+			}
+		}
+	}
+	forEach(0, 4, func(i int) { t.Errorf("forEach with n=0 visited %d", i) })
+}
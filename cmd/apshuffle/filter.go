@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// A metaFilter selects bundles based on the contents of their "meta" files.
+// Each field that's set must match. For the lists, matching any entry is
+// enough.
+type metaFilter struct {
+	since, until time.Time
+
+	hostnames  []string // glob patterns, as for path.Match
+	revisions  []string
+	goVersions []string
+	mains      []string
+
+	minUptime, maxUptime time.Duration
+}
+
+// registerFlags defines the flags that configure the filter. Each flag's name
+// starts with prefix, and its usage text with what.
+func (f *metaFilter) registerFlags(fset *flag.FlagSet, prefix, what string) {
+	fset.Func(prefix+"since", what+` captured at or after this RFC 3339 time, or this long ago (such as "1h")`, func(s string) (err error) {
+		f.since, err = parseTime(s, time.Now())
+		return err
+	})
+	fset.Func(prefix+"until", what+` captured before this RFC 3339 time, or this long ago`, func(s string) (err error) {
+		f.until, err = parseTime(s, time.Now())
+		return err
+	})
+	fset.Func(prefix+"hostname", what+" from hosts matching this glob pattern (may be repeated)", func(s string) error {
+		for _, part := range strings.Split(s, ",") {
+			pattern, err := parseGlob(part)
+			if err != nil {
+				return err
+			}
+			f.hostnames = append(f.hostnames, pattern)
+		}
+		return nil
+	})
+	fset.Func(prefix+"revision", what+" from this revision of the program (may be repeated)", listFlag(&f.revisions))
+	fset.Func(prefix+"go-version", what+` from programs built with this Go version, such as "go1.22.3" (may be repeated)`, listFlag(&f.goVersions))
+	fset.Func(prefix+"main", what+" from programs with this main package (may be repeated)", listFlag(&f.mains))
+	fset.DurationVar(&f.minUptime, prefix+"min-uptime", 0, what+" captured at least this long after their process started")
+	fset.DurationVar(&f.maxUptime, prefix+"max-uptime", 0, what+" captured at most this long after their process started")
+}
+
+func (f *metaFilter) match(m *meta) bool {
+	if !f.since.IsZero() && m.CaptureTime.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !m.CaptureTime.Before(f.until) {
+		return false
+	}
+	if len(f.hostnames) > 0 && !slices.ContainsFunc(f.hostnames, func(pattern string) bool {
+		ok, _ := path.Match(pattern, m.Hostname)
+		return ok
+	}) {
+		return false
+	}
+	if len(f.revisions) > 0 && !slices.Contains(f.revisions, m.Revision) {
+		return false
+	}
+	if len(f.goVersions) > 0 && !slices.Contains(f.goVersions, m.GoVersion) {
+		return false
+	}
+	if len(f.mains) > 0 && !slices.Contains(f.mains, m.Main) {
+		return false
+	}
+	uptime := m.CaptureTime.Sub(m.InitTime)
+	if f.minUptime != 0 && uptime < f.minUptime {
+		return false
+	}
+	if f.maxUptime != 0 && uptime > f.maxUptime {
+		return false
+	}
+	return true
+}
+
+// A sampleFilter selects the samples within a profile.
+type sampleFilter struct {
+	// focus keeps the samples with stacks that match all of the expressions.
+	focus []*regexp.Regexp
+	// ignore removes the samples with stacks that match the expression.
+	ignore *regexp.Regexp
+	// show and hide remove the stack frames that don't match, or that do
+	// match, respectively. Samples with no frames left are removed.
+	show, hide *regexp.Regexp
+	// tagfocus and tagignore keep the samples with labels that match, and
+	// remove those with labels that match, respectively. See parseTagFilter.
+	tagfocus, tagignore         profile.TagMatch
+	tagfocusText, tagignoreText string
+}
+
+func (f *sampleFilter) apply(prof *profile.Profile) {
+	// As in pprof, focus and ignore look at the full stacks, before show and
+	// hide remove any of their frames.
+	if f.ignore != nil {
+		prof.FilterSamplesByName(nil, f.ignore, nil, nil)
+	}
+	for _, re := range f.focus {
+		prof.FilterSamplesByName(re, nil, nil, nil)
+	}
+	if f.tagfocus != nil || f.tagignore != nil {
+		prof.FilterSamplesByTag(f.tagfocus, f.tagignore)
+	}
+	if f.hide != nil || f.show != nil {
+		prof.FilterSamplesByName(nil, nil, f.hide, f.show)
+	}
+}
+
+// key describes the filter, for use in the cache.
+func (f *sampleFilter) key() string {
+	var b strings.Builder
+	for _, re := range f.focus {
+		fmt.Fprintf(&b, "focus=%q ", re)
+	}
+	if f.ignore != nil {
+		fmt.Fprintf(&b, "ignore=%q ", f.ignore)
+	}
+	if f.show != nil {
+		fmt.Fprintf(&b, "show=%q ", f.show)
+	}
+	if f.hide != nil {
+		fmt.Fprintf(&b, "hide=%q ", f.hide)
+	}
+	if f.tagfocus != nil {
+		fmt.Fprintf(&b, "tagfocus=%q ", f.tagfocusText)
+	}
+	if f.tagignore != nil {
+		fmt.Fprintf(&b, "tagignore=%q ", f.tagignoreText)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// parseTime accepts an RFC 3339 timestamp, or a duration to count back from
+// now (so "1h" means "one hour ago").
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", s)
+	}
+	return t, nil
+}
+
+// parseGlob checks that s is a valid pattern for path.Match.
+func parseGlob(s string) (string, error) {
+	if _, err := path.Match(s, ""); err != nil {
+		return "", fmt.Errorf("bad pattern %q: %w", s, err)
+	}
+	return s, nil
+}
+
+// listFlag returns a flag.Func callback that appends each use of the flag to
+// the list. A single use can also provide a comma-separated list.
+func listFlag(list *[]string) func(string) error {
+	return func(s string) error {
+		*list = append(*list, strings.Split(s, ",")...)
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		in   string
+		want time.Time
+		bad  bool
+	}{
+		{in: "2024-05-31T08:30:00Z", want: time.Date(2024, 5, 31, 8, 30, 0, 0, time.UTC)},
+		{in: "2024-05-31T08:30:00-07:00", want: time.Date(2024, 5, 31, 15, 30, 0, 0, time.UTC)},
+		{in: "1h", want: now.Add(-time.Hour)},
+		{in: "90m", want: now.Add(-90 * time.Minute)},
+		{in: "0s", want: now},
+		{in: "2024-05-31", bad: true},
+		{in: "1d", bad: true},
+		{in: "", bad: true},
+	} {
+		have, err := parseTime(tt.in, now)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("parseTime(%q) = %v; expected an error", tt.in, have)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTime(%q); err = %v", tt.in, err)
+			continue
+		}
+		if !have.Equal(tt.want) {
+			t.Errorf("parseTime(%q) = %v, expected %v", tt.in, have, tt.want)
+		}
+	}
+}
+
+func TestMetaFilter(t *testing.T) {
+	init := time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC)
+	m := &meta{
+		Main:        "example.com/cmd/server",
+		Revision:    "abc123",
+		GoVersion:   "go1.22.3",
+		Hostname:    "web-07.us-east",
+		ProcID:      "p1",
+		InitTime:    init,
+		CaptureTime: init.Add(time.Hour),
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{nil, true},
+
+		{[]string{"-since", "2024-06-01T12:00:00Z"}, true},
+		{[]string{"-since", "2024-06-01T12:00:01Z"}, false},
+		{[]string{"-until", "2024-06-01T12:00:01Z"}, true},
+		{[]string{"-until", "2024-06-01T12:00:00Z"}, false},
+		{[]string{"-since", "2024-06-01T11:00:00Z", "-until", "2024-06-01T13:00:00Z"}, true},
+
+		{[]string{"-hostname", "web-07.us-east"}, true},
+		{[]string{"-hostname", "web-*"}, true},
+		{[]string{"-hostname", "web-0?.us-*"}, true},
+		{[]string{"-hostname", "web-[0-5]*"}, true},
+		{[]string{"-hostname", "web-[1-9]*"}, false},
+		{[]string{"-hostname", "db-*"}, false},
+		{[]string{"-hostname", "web"}, false},
+		{[]string{"-hostname", "db-*,web-*"}, true},
+		{[]string{"-hostname", "db-*", "-hostname", "*.us-east"}, true},
+
+		{[]string{"-revision", "abc123"}, true},
+		{[]string{"-revision", "abc"}, false},
+		{[]string{"-revision", "def456,abc123"}, true},
+		{[]string{"-go-version", "go1.22.3"}, true},
+		{[]string{"-go-version", "go1.22"}, false},
+		{[]string{"-main", "example.com/cmd/server"}, true},
+		{[]string{"-main", "example.com/cmd/worker"}, false},
+		{[]string{"-revision", "abc123", "-main", "example.com/cmd/worker"}, false},
+
+		// The uptime bounds include the limit itself.
+		{[]string{"-min-uptime", "59m"}, true},
+		{[]string{"-min-uptime", "1h"}, true},
+		{[]string{"-min-uptime", "1h0m1s"}, false},
+		{[]string{"-max-uptime", "61m"}, true},
+		{[]string{"-max-uptime", "1h"}, true},
+		{[]string{"-max-uptime", "59m59s"}, false},
+		{[]string{"-min-uptime", "1h", "-max-uptime", "1h"}, true},
+	} {
+		var f metaFilter
+		fset := flag.NewFlagSet("apshuffle", flag.ContinueOnError)
+		fset.SetOutput(io.Discard)
+		f.registerFlags(fset, "", "bundles")
+		if err := fset.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q); err = %v", tt.args, err)
+			continue
+		}
+		if have := f.match(m); have != tt.want {
+			t.Errorf("match with %q = %t, expected %t", tt.args, have, tt.want)
+		}
+	}
+}
+
+func TestMetaFilterPrefix(t *testing.T) {
+	var f, base metaFilter
+	fset := flag.NewFlagSet("apshuffle", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+	f.registerFlags(fset, "", "bundles")
+	base.registerFlags(fset, "base-", "base bundles")
+
+	err := fset.Parse([]string{"-hostname", "web-*", "-base-hostname", "db-*", "-base-max-uptime", "1h"})
+	if err != nil {
+		t.Fatalf("Parse; err = %v", err)
+	}
+	if len(f.hostnames) != 1 || f.hostnames[0] != "web-*" || f.maxUptime != 0 {
+		t.Errorf("filter = %+v, expected only hostname web-*", f)
+	}
+	if len(base.hostnames) != 1 || base.hostnames[0] != "db-*" || base.maxUptime != time.Hour {
+		t.Errorf("base filter = %+v, expected hostname db-* and max uptime 1h", base)
+	}
+
+	err = fset.Parse([]string{"-base-hostname", "[bad"})
+	if err == nil {
+		t.Errorf("Parse of bad glob; err = nil")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// groupKeys lists the ways to group bundles for -group-by.
+var groupKeys = map[string]func(m *meta) string{
+	"hostname":   func(m *meta) string { return m.Hostname },
+	"revision":   func(m *meta) string { return m.Revision },
+	"go-version": func(m *meta) string { return m.GoVersion },
+	"proc-id":    func(m *meta) string { return m.ProcID },
+	"main":       func(m *meta) string { return m.Main },
+}
+
+// groupQuantiles lists the points of each group's distribution of values that
+// -group-by reports.
+var groupQuantiles = []float64{0.50, 0.90, 0.99}
+
+// A groupStats describes the values from all of the bundles in a group.
+type groupStats struct {
+	Key string
+	// SampleType names the values' sample type, if they all share one.
+	SampleType string
+	Count      int
+	Sum        *big.Int
+	Mean       *big.Rat
+	Max        *big.Int
+	Quantiles  []*big.Int // at each of groupQuantiles
+}
+
+func groupValues(values map[*meta]*big.Int, sampleTypes map[*meta]string, key func(m *meta) string) []*groupStats {
+	byKey := make(map[string][]*big.Int)
+	typeSets := make(map[string]map[string]bool)
+	for m, v := range values {
+		k := key(m)
+		byKey[k] = append(byKey[k], v)
+		if typeSets[k] == nil {
+			typeSets[k] = make(map[string]bool)
+		}
+		typeSets[k][sampleTypes[m]] = true
+	}
+
+	var groups []*groupStats
+	for k, vs := range byKey {
+		sort.Slice(vs, func(i, j int) bool { return vs[i].Cmp(vs[j]) < 0 })
+		g := &groupStats{
+			Key:   k,
+			Count: len(vs),
+			Sum:   new(big.Int),
+			Max:   vs[len(vs)-1],
+		}
+		if len(typeSets[k]) == 1 {
+			for t := range typeSets[k] {
+				g.SampleType = t
+			}
+		}
+		for _, v := range vs {
+			g.Sum.Add(g.Sum, v)
+		}
+		g.Mean = new(big.Rat).SetFrac(g.Sum, big.NewInt(int64(len(vs))))
+		for _, q := range groupQuantiles {
+			i := int(math.Ceil(q*float64(len(vs)))) - 1
+			g.Quantiles = append(g.Quantiles, vs[max(0, i)])
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+
+	return groups
+}
+
+func printGroups(groups []*groupStats) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "group\tcount\tsum\tmean\tmax")
+	for _, q := range groupQuantiles {
+		fmt.Fprintf(tw, "\tp%s", strconv.FormatFloat(q*100, 'g', 4, 64))
+	}
+	fmt.Fprintf(tw, "\n")
+	for _, g := range groups {
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%s\t%v", g.Key, g.Count, g.Sum, g.Mean.FloatString(1), g.Max)
+		for _, v := range g.Quantiles {
+			fmt.Fprintf(tw, "\t%v", v)
+		}
+		fmt.Fprintf(tw, "\n")
+	}
+	tw.Flush()
+}
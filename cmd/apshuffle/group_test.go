@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestGroupValues(t *testing.T) {
+	values := make(map[*meta]*big.Int)
+	sampleTypes := make(map[*meta]string)
+	add := func(host string, sampleType string, vs ...int64) {
+		for i, v := range vs {
+			m := &meta{Hostname: host, ProcID: fmt.Sprintf("%s-%d", host, i)}
+			values[m] = big.NewInt(v)
+			sampleTypes[m] = sampleType
+		}
+	}
+	// The values are out of order, to check that groupValues sorts them.
+	add("ten", "cpu", 7, 3, 10, 1, 9, 2, 8, 4, 6, 5)
+	add("one", "cpu", 42)
+	add("two", "cpu", 20, 10)
+	add("mixed", "cpu", 1)
+	add("mixed", "alloc_space", 3)
+
+	type want struct {
+		key        string
+		sampleType string
+		count      int
+		sum        int64
+		mean       string
+		max        int64
+		quantiles  [3]int64 // p50, p90, p99
+	}
+	wants := []want{
+		{"mixed", "", 2, 4, "2.0", 3, [3]int64{1, 3, 3}},
+		{"one", "cpu", 1, 42, "42.0", 42, [3]int64{42, 42, 42}},
+		{"ten", "cpu", 10, 55, "5.5", 10, [3]int64{5, 9, 10}},
+		{"two", "cpu", 2, 30, "15.0", 20, [3]int64{10, 20, 20}},
+	}
+
+	groups := groupValues(values, sampleTypes, groupKeys["hostname"])
+	if len(groups) != len(wants) {
+		t.Fatalf("groupValues found %d groups, expected %d", len(groups), len(wants))
+	}
+	for i, w := range wants {
+		g := groups[i]
+		if g.Key != w.key {
+			t.Errorf("group %d has key %q, expected %q", i, g.Key, w.key)
+			continue
+		}
+		if g.SampleType != w.sampleType {
+			t.Errorf("group %q has sample type %q, expected %q", g.Key, g.SampleType, w.sampleType)
+		}
+		if g.Count != w.count {
+			t.Errorf("group %q has count %d, expected %d", g.Key, g.Count, w.count)
+		}
+		if g.Sum.Int64() != w.sum {
+			t.Errorf("group %q has sum %v, expected %d", g.Key, g.Sum, w.sum)
+		}
+		if have := g.Mean.FloatString(1); have != w.mean {
+			t.Errorf("group %q has mean %s, expected %s", g.Key, have, w.mean)
+		}
+		if g.Max.Int64() != w.max {
+			t.Errorf("group %q has max %v, expected %d", g.Key, g.Max, w.max)
+		}
+		if len(g.Quantiles) != len(groupQuantiles) {
+			t.Errorf("group %q has %d quantiles, expected %d", g.Key, len(g.Quantiles), len(groupQuantiles))
+			continue
+		}
+		for j, q := range groupQuantiles {
+			if have := g.Quantiles[j].Int64(); have != w.quantiles[j] {
+				t.Errorf("group %q has p%g %d, expected %d", g.Key, q*100, have, w.quantiles[j])
+			}
+		}
+	}
+}
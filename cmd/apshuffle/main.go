@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
+	"math"
 	"math/big"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
 	"time"
@@ -24,64 +26,190 @@ func main() {
 	root := flag.String("C", ".", "change to directory before running")
 
 	requireTrace := flag.Bool("with-trace", false, "Filter to bundles that include an execution trace")
+
+	var filter metaFilter
+	filter.registerFlags(flag.CommandLine, "", "Filter to bundles")
 	doPrintHosts := flag.Bool("hosts", false, "print host list")
 	doProfileSort := flag.String("profile-sort", "", `name of profile to use for sorting ("goroutine", "profile", etc)`)
 	doWriteLinks := flag.Bool("write-links", false, "Create prev/next symlinks between bundles from the same process")
 	vsPrev := flag.Bool("vs-prev", false, "Use the previous profile from the same process as a diff base")
 	sampleType := flag.String("sample-type", "", `Name of sample type to use for sorting ("inuse_space", "alloc_objects", "contentions", etc)`)
-
-	var focus []*regexp.Regexp
+	mergeOut := flag.String("merge", "", "Merge the -profile-sort profile from the matching bundles into one, and write it to this file")
+	mergeTop := flag.Int("merge-top", 0, "Merge only the first N bundles in -profile-sort order (0 for all)")
+	mergeLabelList := flag.String("merge-labels", "", `Comma-separated list of labels to add to each merged sample: "hostname", "revision", and "bundle"`)
+	seriesOut := flag.String("time-series", "", "Write an SVG chart of each process's -profile-sort values over time to this file, and list the values in that order")
+	rankAnomaly := flag.Bool("anomaly", false, "Sort by how unusual each bundle's value is, compared to its peers and to its own process's history")
+	anomalyWindow := flag.Duration("anomaly-window", time.Hour, "With -anomaly, the peers of a bundle are from the same revision and were captured in the same window of this size")
+	vsBase := flag.String("vs-base", "", `Compare each bundle's value to the "median", "mean", "min", or "max" value of the baseline bundles chosen with the -base-* flags`)
+	rankBy := flag.String("rank-by", "delta", `With -vs-base, sort by the "delta" from the baseline value or by the "ratio" to it`)
+	mergeBaseOut := flag.String("merge-base", "", "With -vs-base and -merge, also merge the baseline bundles' profiles into one, and write it to this file for use as a -diff_base")
+	var baseFilter metaFilter
+	baseFilter.registerFlags(flag.CommandLine, "base-", "With -vs-base, use baseline bundles")
+	format := flag.String("format", "text", `Output format: "text", "jsonl" for JSON lines, or "csv"`)
+	groupBy := flag.String("group-by", "", `Aggregate the -profile-sort values of the bundles in each "hostname", "revision", "go-version", "proc-id", or "main"`)
+	workers := flag.Int("parallel", runtime.GOMAXPROCS(0), "Number of bundle files to read and parse at once")
+	cacheFile := flag.String("cache", "auto", `Path to file for remembering bundle metadata and profile totals between runs ("auto" for the user cache directory, "" to disable)`)
+
+	var samples sampleFilter
 	flag.Func("focus", "Filter profile samples to matching stacks", func(s string) error {
 		re, err := regexp.Compile(s)
 		if err != nil {
 			return err
 		}
-		focus = append(focus, re)
+		samples.focus = append(samples.focus, re)
 		return nil
 	})
 
-	var ignore *regexp.Regexp
 	flag.Func("ignore", "Filter profile samples to remove matching stacks", func(s string) error {
 		re, err := regexp.Compile(s)
 		if err != nil {
 			return err
 		}
-		if ignore != nil {
+		if samples.ignore != nil {
 			return fmt.Errorf("this flag may only be provided once")
 		}
-		ignore = re
+		samples.ignore = re
 		return nil
 	})
 
+	regexpFlag := func(re **regexp.Regexp) func(string) error {
+		return func(s string) error {
+			if *re != nil {
+				return fmt.Errorf("this flag may only be provided once")
+			}
+			var err error
+			*re, err = regexp.Compile(s)
+			return err
+		}
+	}
+	flag.Func("show", "Remove the stack frames that don't match from profile samples", regexpFlag(&samples.show))
+	flag.Func("hide", "Remove the stack frames that match from profile samples", regexpFlag(&samples.hide))
+	flag.Func("tagfocus", `Filter profile samples to those with matching labels, as "key=regexp" or "key=10kb:" (see "go tool pprof")`,
+		tagFilterFlag("tagfocus", &samples.tagfocus, &samples.tagfocusText))
+	flag.Func("tagignore", `Filter profile samples to remove those with matching labels, as for -tagfocus`,
+		tagFilterFlag("tagignore", &samples.tagignore, &samples.tagignoreText))
+
 	flag.Parse()
 
-	err := os.Chdir(*root)
+	groupKey, ok := groupKeys[*groupBy]
+	if *groupBy != "" && !ok {
+		log.Fatalf("Unknown -group-by value %q", *groupBy)
+	}
+	labels, err := parseMergeLabels(*mergeLabelList)
+	if err != nil {
+		log.Fatalf("Bad -merge-labels value: %v", err)
+	}
+	var baseStat func(sorted []*big.Int) *big.Int
+	if *vsBase != "" {
+		baseStat, ok = baseStats[*vsBase]
+		if !ok {
+			log.Fatalf("Unknown -vs-base value %q", *vsBase)
+		}
+		if *vsPrev {
+			log.Fatalf("Use at most one of -vs-prev and -vs-base")
+		}
+	}
+	switch *rankBy {
+	case "delta", "ratio":
+	default:
+		log.Fatalf("Unknown -rank-by value %q", *rankBy)
+	}
+	if *mergeBaseOut != "" && (*vsBase == "" || *mergeOut == "") {
+		log.Fatalf("The -merge-base flag requires -vs-base and -merge")
+	}
+	if *seriesOut != "" && (*rankAnomaly || *rankBy == "ratio" || *mergeTop > 0) {
+		// The time series lists the bundles by process and capture time,
+		// rather than by rank.
+		log.Fatalf("The -time-series flag can't be used with -anomaly, -rank-by=ratio, or -merge-top")
+	}
+	// The output paths are relative to where we started, not to -C.
+	for _, out := range []*string{mergeOut, mergeBaseOut, seriesOut} {
+		if *out != "" {
+			*out, err = filepath.Abs(*out)
+			if err != nil {
+				log.Fatalf("filepath.Abs: %v", err)
+			}
+		}
+	}
+
+	switch *format {
+	case "text", "jsonl", "csv":
+	default:
+		log.Fatalf("Unknown -format value %q", *format)
+	}
+
+	err = os.Chdir(*root)
 	if err != nil {
 		log.Fatalf("os.Chdir: %v", err)
 	}
 
-	fsys := os.DirFS(".")
+	if *cacheFile == "auto" {
+		*cacheFile, err = defaultCachePath(".")
+		if err != nil {
+			log.Fatalf("defaultCachePath; err = %v", err)
+		}
+	}
+	cache, err := loadCache(*cacheFile)
+	if err != nil {
+		log.Fatalf("loadCache; err = %v", err)
+	}
+
+	fsys := newArchiveFS(os.DirFS("."))
 
 	files, err := allFiles(fsys)
 	if err != nil {
 		log.Fatalf("allFiles; err = %v", err)
 	}
 
-	allMeta, err := readMetas(fsys, files)
+	allMeta, err := readMetas(fsys, cache, *workers, files)
 	if err != nil {
 		log.Fatalf("readMetas; err = %v", err)
 	}
 
+	err = cache.save(files)
+	if err != nil {
+		log.Printf("save cache; err = %v", err)
+	}
+
 	allMeta = sortedMetas(allMeta)
 
 	if *doWriteLinks {
-		err := writeLinks(allMeta)
+		// The links go next to the bundles, which can't be inside of
+		// archives.
+		var ms []*meta
+		for _, m := range allMeta {
+			if !fsys.inArchive(m.dir) {
+				ms = append(ms, m)
+			}
+		}
+		err := writeLinks(ms)
 		if err != nil {
 			log.Fatalf("create symlink tree: %v", err)
 		}
 		return
 	}
 
+	// The baseline may come from outside of the bundles that the other
+	// flags select, such as from the previous revision.
+	var baseMeta []*meta
+	if baseStat != nil {
+		for _, m := range allMeta {
+			if baseFilter.match(m) {
+				baseMeta = append(baseMeta, m)
+			}
+		}
+	}
+
+	{
+		var ms []*meta
+		for _, m := range allMeta {
+			if filter.match(m) {
+				ms = append(ms, m)
+			}
+		}
+		allMeta = ms
+	}
+
 	if *requireTrace {
 		var ms []*meta
 		for _, m := range allMeta {
@@ -98,14 +226,37 @@ func main() {
 	}
 
 	if *doPrintHosts {
-		printHosts(allMeta)
+		hosts := sortedHosts(allMeta)
+		if *format == "text" {
+			printHosts(hosts)
+			return
+		}
+		var records []*hostRecord
+		for _, host := range hosts {
+			records = append(records, &hostRecord{Hostname: host})
+		}
+		err := writeRecords(os.Stdout, *format, records)
+		if err != nil {
+			log.Fatalf("writeRecords: %v", err)
+		}
 		return
 	}
 
 	if *doProfileSort != "" {
-		values := profileCount(fsys, allMeta, focus, ignore, path.Clean(*doProfileSort), *sampleType)
+		profileName := path.Clean(*doProfileSort)
+		values, sampleTypes := profileCount(fsys, cache, *workers, allMeta, &samples, profileName, *sampleType)
+		var baseValues map[*meta]*big.Int
+		if baseStat != nil {
+			baseValues, _ = profileCount(fsys, cache, *workers, baseMeta, &samples, profileName, *sampleType)
+		}
+		err := cache.save(files)
+		if err != nil {
+			log.Printf("save cache; err = %v", err)
+		}
 		sorted := profileSort(values)
 
+		var prev map[*meta]*meta
+
 		if *vsPrev {
 			// This compares against the previous profile from the same instance
 			// of the process. Usually that will be from the previous bundle,
@@ -114,7 +265,7 @@ func main() {
 			// will cause this behavior to be different (better?) than a user
 			// would get from running
 			//   "go tool pprof -base {_link/prev/,}pprof/foo"
-			prev := findPrev(sorted)
+			prev = findPrev(sorted)
 			delta := make(map[*meta]*big.Int)
 			for m1, m0 := range prev {
 				delta[m1] = new(big.Int).Sub(values[m1], values[m0])
@@ -123,7 +274,120 @@ func main() {
 			sorted = profileSort(values)
 		}
 
-		printCounts(sorted, values)
+		var diffs map[*meta]*baseDiff
+		if baseStat != nil {
+			if len(baseValues) == 0 {
+				log.Fatalf("No baseline bundles include a %q profile", profileName)
+			}
+			diffs = diffVsBase(values, baseValue(baseValues, baseStat))
+			delta := make(map[*meta]*big.Int, len(diffs))
+			for m, d := range diffs {
+				delta[m] = d.delta
+			}
+			values = delta
+			sorted = profileSort(values)
+			if *rankBy == "ratio" {
+				sorted = ratioSort(diffs)
+			}
+		}
+
+		var scores map[*meta]*anomaly
+		if *rankAnomaly {
+			scores = anomalyScores(values, *anomalyWindow)
+			sorted = anomalySort(scores)
+		}
+
+		var all []*series
+		if *seriesOut != "" {
+			all = timeSeries(values)
+			title := profileName
+			if *sampleType != "" {
+				title += " " + *sampleType
+			}
+			err := os.WriteFile(*seriesOut, renderTimeSeries(all, title), 0644)
+			if err != nil {
+				log.Fatalf("WriteFile: %v", err)
+			}
+			sorted = nil
+			for _, s := range all {
+				sorted = append(sorted, s.metas...)
+			}
+		}
+
+		if *mergeOut != "" {
+			prof, err := mergeProfiles(fsys, *workers, sorted, *mergeTop, &samples, profileName, labels)
+			if err != nil {
+				log.Fatalf("mergeProfiles: %v", err)
+			}
+			err = writeProfile(*mergeOut, prof)
+			if err != nil {
+				log.Fatalf("writeProfile: %v", err)
+			}
+			if *mergeBaseOut != "" {
+				var ms []*meta
+				for m := range baseValues {
+					ms = append(ms, m)
+				}
+				prof, err := mergeProfiles(fsys, *workers, sortedMetas(ms), 0, &samples, profileName, labels)
+				if err != nil {
+					log.Fatalf("mergeProfiles: %v", err)
+				}
+				err = writeProfile(*mergeBaseOut, prof)
+				if err != nil {
+					log.Fatalf("writeProfile: %v", err)
+				}
+			}
+			return
+		}
+
+		if groupKey != nil {
+			groups := groupValues(values, sampleTypes, groupKey)
+			if *format == "text" {
+				printGroups(groups)
+				return
+			}
+			var records []*groupRecord
+			for _, g := range groups {
+				records = append(records, newGroupRecord(*groupBy, profileName, g))
+			}
+			err = writeRecords(os.Stdout, *format, records)
+			if err != nil {
+				log.Fatalf("writeRecords: %v", err)
+			}
+			return
+		}
+
+		if *format == "text" {
+			if all != nil {
+				printSeries(all)
+				return
+			}
+			if scores != nil {
+				printAnomalies(sorted, values, scores)
+				return
+			}
+			if diffs != nil {
+				printDiffs(sorted, diffs)
+				return
+			}
+			printCounts(sorted, values)
+			return
+		}
+		var records []*countRecord
+		for _, m := range sorted {
+			rec := newCountRecord(m, profileName, sampleTypes[m], values[m], prev[m])
+			if a := scores[m]; a != nil {
+				rec.PeerScore, rec.HistoryScore = finite(a.peer), finite(a.history)
+			}
+			if d := diffs[m]; d != nil {
+				rec.BaseValue, rec.Ratio = d.base, finite(d.ratio)
+			}
+			records = append(records, rec)
+		}
+		err = writeRecords(os.Stdout, *format, records)
+		if err != nil {
+			log.Fatalf("writeRecords: %v", err)
+		}
 		return
 	}
 }
@@ -148,29 +412,57 @@ func allFiles(fsys fs.FS) ([]string, error) {
 	return files, err
 }
 
-func readMetas(fsys fs.FS, files []string) ([]*meta, error) {
-	var ms []*meta
+func readMetas(fsys fs.FS, cache *bundleCache, workers int, files []string) ([]*meta, error) {
+	var metaFiles []string
 	for _, file := range files {
-		if path.Base(file) != "meta" {
-			continue
+		if path.Base(file) == "meta" {
+			metaFiles = append(metaFiles, file)
 		}
+	}
 
-		buf, err := fs.ReadFile(fsys, file)
-		if err != nil {
-			return nil, fmt.Errorf("ReadFile(%q): %w", file, err)
-		}
-		var m meta
-		err = json.Unmarshal(buf, &m)
+	ms := make([]*meta, len(metaFiles))
+	errs := make([]error, len(metaFiles))
+	forEach(len(metaFiles), workers, func(i int) {
+		ms[i], errs[i] = readMeta(fsys, cache, metaFiles[i])
+	})
+	for _, err := range errs {
 		if err != nil {
-			return nil, fmt.Errorf("Unmarshal(%q): %w", file, err)
+			return nil, err
 		}
-		m.dir = path.Dir(file)
-		ms = append(ms, &m)
 	}
 
 	return ms, nil
 }
 
+func readMeta(fsys fs.FS, cache *bundleCache, file string) (*meta, error) {
+	info, err := fs.Stat(fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("Stat(%q): %w", file, err)
+	}
+	if e := cache.lookup(file, info); e != nil && e.Meta != nil {
+		m := *e.Meta
+		m.dir = path.Dir(file)
+		return &m, nil
+	}
+
+	buf, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile(%q): %w", file, err)
+	}
+	var m meta
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal(%q): %w", file, err)
+	}
+	m.dir = path.Dir(file)
+
+	cache.update(file, info, func(e *cacheEntry) {
+		cm := m
+		e.Meta = &cm
+	})
+	return &m, nil
+}
+
 func sortedMetas(ms []*meta) []*meta {
 	if len(ms) == 0 {
 		return nil
@@ -193,7 +485,7 @@ func sortedMetas(ms []*meta) []*meta {
 	return ms2
 }
 
-func printHosts(ms []*meta) {
+func sortedHosts(ms []*meta) []string {
 	hostSet := make(map[string]struct{})
 	for _, m := range ms {
 		host := m.Hostname
@@ -226,6 +518,10 @@ func printHosts(ms []*meta) {
 		return false
 	})
 
+	return hosts
+}
+
+func printHosts(hosts []string) {
 	for _, host := range hosts {
 		fmt.Printf("%s\n", host)
 	}
@@ -238,6 +534,46 @@ func printCounts(order []*meta, values map[*meta]*big.Int) {
 	}
 }
 
+func printSeries(all []*series) {
+	for _, s := range all {
+		for i, m := range s.metas {
+			fmt.Printf("%v %v proc=%s time=%s\n", m.dir, s.raw[i], s.procID, m.CaptureTime.UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+func printAnomalies(order []*meta, values map[*meta]*big.Int, scores map[*meta]*anomaly) {
+	format := func(z float64) string {
+		if math.IsNaN(z) {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", z)
+	}
+	for _, m := range order {
+		a := scores[m]
+		fmt.Printf("%v %v peer=%s history=%s\n", m.dir, values[m], format(a.peer), format(a.history))
+	}
+}
+
+func printDiffs(order []*meta, diffs map[*meta]*baseDiff) {
+	for _, m := range order {
+		d := diffs[m]
+		ratio := "-"
+		if !math.IsNaN(d.ratio) {
+			ratio = fmt.Sprintf("%.3f", d.ratio)
+		}
+		fmt.Printf("%v %v base=%v ratio=%s\n", m.dir, d.delta, d.base, ratio)
+	}
+}
+
+// finite returns a pointer to v, or nil if v is NaN or infinite.
+func finite(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
 func profileSort(values map[*meta]*big.Int) []*meta {
 	var sorted []*meta
 	for m := range values {
@@ -265,46 +601,109 @@ func profileSort(values map[*meta]*big.Int) []*meta {
 	return sorted
 }
 
-func profileCount(fsys fs.FS, ms []*meta, focus []*regexp.Regexp, ignore *regexp.Regexp, profileName string, sampleType string) map[*meta]*big.Int {
-	values := make(map[*meta]*big.Int)
-	for _, m := range ms {
-		buf, err := fs.ReadFile(fsys, path.Join(m.dir, "pprof", profileName))
-		if err != nil {
-			// not all bundles include all profile types. ok to skip.
-			continue
-		}
-		prof, err := profile.Parse(bytes.NewReader(buf))
-		if err != nil {
-			continue
+func profileCount(fsys fs.FS, cache *bundleCache, workers int, ms []*meta, filter *sampleFilter, profileName string, sampleType string) (map[*meta]*big.Int, map[*meta]string) {
+	key := filter.key()
+	sums := make([]*big.Int, len(ms))
+	types := make([]string, len(ms))
+	forEach(len(ms), workers, func(i int) {
+		file := path.Join(ms[i].dir, "pprof", profileName)
+		totals := profileTotalsFor(fsys, cache, file, filter)
+		if totals == nil {
+			return
 		}
 
 		sampleIndex := 0
-		for i, t := range prof.SampleType {
+		for j, t := range totals.SampleTypes {
 			want := sampleType
 			if want == "" {
-				want = prof.DefaultSampleType
+				want = totals.DefaultSampleType
 			}
-			if t.Type == want {
-				sampleIndex = i
+			if t == want {
+				sampleIndex = j
 				break
 			}
 		}
 
-		prof.FilterSamplesByName(nil, ignore, nil, nil)
-		for _, re := range focus {
-			prof.FilterSamplesByName(re, nil, nil, nil)
+		sum := new(big.Int)
+		if vals := totals.Totals[key]; sampleIndex < len(vals) {
+			sum.Set(vals[sampleIndex])
+		}
+		sums[i] = sum
+		if sampleIndex < len(totals.SampleTypes) {
+			types[i] = totals.SampleTypes[sampleIndex]
 		}
+	})
 
-		sum := new(big.Int)
-		values[m] = sum
+	values := make(map[*meta]*big.Int)
+	sampleTypes := make(map[*meta]string)
+	for i, m := range ms {
+		if sums[i] != nil {
+			values[m] = sums[i]
+			sampleTypes[m] = types[i]
+		}
+	}
 
-		for _, s := range prof.Sample {
-			val := s.Value[sampleIndex]
-			sum.Add(sum, big.NewInt(val))
+	return values, sampleTypes
+}
+
+// profileTotalsFor returns the sums of the values of each sample type in the
+// profile, after filtering the samples, or nil if the file is missing or isn't
+// a profile. The result includes the totals for filter, and possibly for other
+// filters.
+func profileTotalsFor(fsys fs.FS, cache *bundleCache, file string, filter *sampleFilter) *profileTotals {
+	key := filter.key()
+	info, err := fs.Stat(fsys, file)
+	if err != nil {
+		// not all bundles include all profile types. ok to skip.
+		return nil
+	}
+	if e := cache.lookup(file, info); e != nil && e.Profile != nil {
+		if e.Profile.Invalid {
+			return nil
+		}
+		if _, ok := e.Profile.Totals[key]; ok {
+			return e.Profile
 		}
 	}
 
-	return values
+	buf, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil
+	}
+	prof, err := profile.Parse(bytes.NewReader(buf))
+	if err != nil {
+		cache.update(file, info, func(e *cacheEntry) {
+			e.Profile = &profileTotals{Invalid: true}
+		})
+		return nil
+	}
+
+	filter.apply(prof)
+
+	totals := &profileTotals{
+		DefaultSampleType: prof.DefaultSampleType,
+		Totals:            make(map[string][]*big.Int),
+	}
+	sums := make([]*big.Int, len(prof.SampleType))
+	for i, t := range prof.SampleType {
+		totals.SampleTypes = append(totals.SampleTypes, t.Type)
+		sums[i] = new(big.Int)
+	}
+	for _, s := range prof.Sample {
+		for i, val := range s.Value {
+			sums[i].Add(sums[i], big.NewInt(val))
+		}
+	}
+	totals.Totals[key] = sums
+
+	cache.update(file, info, func(e *cacheEntry) {
+		if e.Profile == nil || e.Profile.Invalid {
+			e.Profile = totals
+			return
+		}
+		e.Profile.Totals[key] = sums
+	})
+	return totals
 }
 
 type meta struct {
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// mergeLabels lists the labels that -merge-labels can add to each sample, to
+// show which bundle it came from.
+var mergeLabels = map[string]func(m *meta) string{
+	"hostname": func(m *meta) string { return m.Hostname },
+	"revision": func(m *meta) string { return m.Revision },
+	"bundle":   func(m *meta) string { return m.dir },
+}
+
+// parseMergeLabels checks a comma-separated list of keys of mergeLabels.
+func parseMergeLabels(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	keys := strings.Split(s, ",")
+	for _, key := range keys {
+		if _, ok := mergeLabels[key]; !ok {
+			return nil, fmt.Errorf("unknown label %q", key)
+		}
+	}
+	return keys, nil
+}
+
+// mergeProfiles reads the named profile from each bundle, filters its samples,
+// and combines them into a single profile. When top is more than 0, it uses
+// only the first top bundles. It labels each sample with the keys of
+// mergeLabels that are listed in labels.
+func mergeProfiles(fsys fs.FS, workers int, ms []*meta, top int, filter *sampleFilter, profileName string, labels []string) (*profile.Profile, error) {
+	if top > 0 && len(ms) > top {
+		ms = ms[:top]
+	}
+	profs := make([]*profile.Profile, len(ms))
+	errs := make([]error, len(ms))
+	forEach(len(ms), workers, func(i int) {
+		m := ms[i]
+		file := path.Join(m.dir, "pprof", profileName)
+		buf, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			// not all bundles include all profile types. ok to skip.
+			return
+		}
+		prof, err := profile.Parse(bytes.NewReader(buf))
+		if err != nil {
+			errs[i] = fmt.Errorf("Parse(%q): %w", file, err)
+			return
+		}
+		filter.apply(prof)
+		for _, key := range labels {
+			value := mergeLabels[key](m)
+			for _, s := range prof.Sample {
+				if s.Label == nil {
+					s.Label = make(map[string][]string)
+				}
+				s.Label[key] = []string{value}
+			}
+		}
+		profs[i] = prof
+	})
+
+	var all []*profile.Profile
+	for i, prof := range profs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if prof != nil {
+			all = append(all, prof)
+		}
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no bundles include a %q profile", profileName)
+	}
+	return profile.Merge(all)
+}
+
+// writeProfile writes the profile to the named file, in gzip-compressed
+// protobuf format.
+func writeProfile(name string, prof *profile.Profile) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = prof.Write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/pprof/profile"
+)
+
+// testProfile returns a goroutine profile with a single stack, in the
+// compressed protobuf format.
+func testProfile(t *testing.T, count int64) []byte {
+	fn := &profile.Function{ID: 1, Name: "main.worker"}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn, Line: 10}}}
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "goroutine", Unit: "count"}},
+		PeriodType: &profile.ValueType{Type: "goroutine", Unit: "count"},
+		Period:     1,
+		Function:   []*profile.Function{fn},
+		Location:   []*profile.Location{loc},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{count}}},
+	}
+	var buf bytes.Buffer
+	if err := prof.Write(&buf); err != nil {
+		t.Fatalf("Write; err = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestMergeProfiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"host1/b1/pprof/goroutine": {Data: testProfile(t, 1)},
+		"host2/b1/pprof/goroutine": {Data: testProfile(t, 2)},
+		"host2/b2/meta":            {Data: []byte("no profiles")},
+		"host3/b1/pprof/goroutine": {Data: testProfile(t, 4)},
+	}
+	ms := []*meta{
+		{dir: "host1/b1", Hostname: "host1", Revision: "abc"},
+		{dir: "host2/b1", Hostname: "host2", Revision: "abc"},
+		{dir: "host2/b2", Hostname: "host2", Revision: "abc"},
+		{dir: "host3/b1", Hostname: "host3", Revision: "def"},
+	}
+
+	// values maps a description of each sample's labels to its value.
+	values := func(prof *profile.Profile) map[string]int64 {
+		out := make(map[string]int64)
+		for _, s := range prof.Sample {
+			var keys []string
+			for k, v := range s.Label {
+				keys = append(keys, k+"="+strings.Join(v, ","))
+			}
+			sort.Strings(keys)
+			out[strings.Join(keys, " ")] += s.Value[0]
+		}
+		return out
+	}
+
+	for _, tt := range []struct {
+		name   string
+		top    int
+		labels []string
+		want   map[string]int64
+	}{
+		{"all", 0, nil, map[string]int64{"": 7}},
+		{"top", 2, nil, map[string]int64{"": 3}},
+		{"top beyond end", 10, nil, map[string]int64{"": 7}},
+		{"revision", 0, []string{"revision"}, map[string]int64{
+			"revision=abc": 3,
+			"revision=def": 4,
+		}},
+		{"hostname and bundle", 0, []string{"hostname", "bundle"}, map[string]int64{
+			"bundle=host1/b1 hostname=host1": 1,
+			"bundle=host2/b1 hostname=host2": 2,
+			"bundle=host3/b1 hostname=host3": 4,
+		}},
+		{"top with labels", 1, []string{"hostname"}, map[string]int64{
+			"hostname=host1": 1,
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			prof, err := mergeProfiles(fsys, 2, ms, tt.top, &sampleFilter{}, "goroutine", tt.labels)
+			if err != nil {
+				t.Fatalf("mergeProfiles; err = %v", err)
+			}
+			if have := values(prof); !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("mergeProfiles values = %v, expected %v", have, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := mergeProfiles(fsys, 2, ms, 0, &sampleFilter{}, "heap", nil)
+		if err == nil {
+			t.Errorf("mergeProfiles with no heap profiles; err = nil")
+		}
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"host1/b1/pprof/goroutine": {Data: testProfile(t, 1)},
+			"host2/b1/pprof/goroutine": {Data: []byte("not a profile")},
+		}
+		_, err := mergeProfiles(fsys, 2, ms[:2], 0, &sampleFilter{}, "goroutine", nil)
+		if err == nil || !strings.Contains(err.Error(), "host2/b1") {
+			t.Errorf("mergeProfiles with corrupt profile; err = %v, expected it to name the file", err)
+		}
+	})
+}
+
+func TestParseMergeLabels(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []string
+		bad  bool
+	}{
+		{in: "", want: nil},
+		{in: "hostname", want: []string{"hostname"}},
+		{in: "hostname,revision,bundle", want: []string{"hostname", "revision", "bundle"}},
+		{in: "hostname,proc-id", bad: true},
+	} {
+		have, err := parseMergeLabels(tt.in)
+		if (err != nil) != tt.bad {
+			t.Errorf("parseMergeLabels(%q); err = %v", tt.in, err)
+			continue
+		}
+		if !tt.bad && !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("parseMergeLabels(%q) = %q, expected %q", tt.in, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A countRecord describes the value that -profile-sort found in a bundle.
+type countRecord struct {
+	Dir         string    `json:"dir"`
+	Main        string    `json:"main"`
+	Revision    string    `json:"revision"`
+	GoVersion   string    `json:"go_version"`
+	Hostname    string    `json:"hostname"`
+	ProcID      string    `json:"proc_id"`
+	InitTime    time.Time `json:"init_time"`
+	CaptureTime time.Time `json:"capture_time"`
+	Profile     string    `json:"profile"`
+	SampleType  string    `json:"sample_type"`
+	Value       *big.Int  `json:"value"`
+	// BaseDir is the bundle that -vs-prev subtracted from this one.
+	BaseDir string `json:"base_dir,omitempty"`
+	// PeerScore and HistoryScore are from -anomaly, when available.
+	PeerScore    *float64 `json:"peer_score,omitempty"`
+	HistoryScore *float64 `json:"history_score,omitempty"`
+	// BaseValue is the baseline that -vs-base subtracted from this bundle's
+	// value, and Ratio is the bundle's value divided by it.
+	BaseValue *big.Int `json:"base_value,omitempty"`
+	Ratio     *float64 `json:"ratio,omitempty"`
+}
+
+func newCountRecord(m *meta, profileName, sampleType string, v *big.Int, base *meta) *countRecord {
+	rec := &countRecord{
+		Dir:         m.dir,
+		Main:        m.Main,
+		Revision:    m.Revision,
+		GoVersion:   m.GoVersion,
+		Hostname:    m.Hostname,
+		ProcID:      m.ProcID,
+		InitTime:    m.InitTime,
+		CaptureTime: m.CaptureTime,
+		Profile:     profileName,
+		SampleType:  sampleType,
+		Value:       v,
+	}
+	if base != nil {
+		rec.BaseDir = base.dir
+	}
+	return rec
+}
+
+// A groupRecord describes the values that -profile-sort found in a group of
+// bundles, as for -group-by. Its percentiles are those of groupQuantiles.
+type groupRecord struct {
+	GroupBy    string   `json:"group_by"`
+	Group      string   `json:"group"`
+	Profile    string   `json:"profile"`
+	SampleType string   `json:"sample_type"`
+	Count      int      `json:"count"`
+	Sum        *big.Int `json:"sum"`
+	Mean       float64  `json:"mean"`
+	Max        *big.Int `json:"max"`
+	P50        *big.Int `json:"p50"`
+	P90        *big.Int `json:"p90"`
+	P99        *big.Int `json:"p99"`
+}
+
+func newGroupRecord(groupBy string, profileName string, g *groupStats) *groupRecord {
+	mean, _ := g.Mean.Float64()
+	return &groupRecord{
+		GroupBy:    groupBy,
+		Group:      g.Key,
+		Profile:    profileName,
+		SampleType: g.SampleType,
+		Count:      g.Count,
+		Sum:        g.Sum,
+		Mean:       mean,
+		Max:        g.Max,
+		P50:        g.Quantiles[0],
+		P90:        g.Quantiles[1],
+		P99:        g.Quantiles[2],
+	}
+}
+
+type hostRecord struct {
+	Hostname string `json:"hostname"`
+}
+
+// writeRecords writes the records as JSON lines or as CSV with a header row.
+// The CSV columns are named for the fields' JSON keys, and the header comes
+// from the type of record, so it's there even when there are no records.
+func writeRecords[T any](w io.Writer, format string, records []*T) error {
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, rec := range records {
+			err := enc.Encode(rec)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		typ := reflect.TypeFor[T]()
+		var header []string
+		for j := 0; j < typ.NumField(); j++ {
+			name, _, _ := strings.Cut(typ.Field(j).Tag.Get("json"), ",")
+			header = append(header, name)
+		}
+		cw.Write(header)
+		for _, rec := range records {
+			v := reflect.ValueOf(rec).Elem()
+			var row []string
+			for j := 0; j < v.NumField(); j++ {
+				row = append(row, csvValue(v.Field(j).Interface()))
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func csvValue(v any) string {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return ""
+		}
+		if _, ok := v.(encoding.TextMarshaler); !ok {
+			v = rv.Elem().Interface()
+		}
+	}
+	if m, ok := v.(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(text)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestWriteRecords(t *testing.T) {
+	ratio := 1.5
+	init := time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC)
+	recs := []*countRecord{{
+		Dir:         "host1/b1",
+		Main:        "example.com/cmd/server",
+		Revision:    "abc123",
+		GoVersion:   "go1.22.3",
+		Hostname:    "host1",
+		ProcID:      "p1",
+		InitTime:    init,
+		CaptureTime: init.Add(time.Hour),
+		Profile:     "goroutine",
+		SampleType:  "goroutine",
+		Value:       big.NewInt(300),
+		BaseValue:   big.NewInt(200),
+		Ratio:       &ratio,
+	}, {
+		Dir:         "host2/b1",
+		Main:        "example.com/cmd/server",
+		Revision:    "abc123",
+		GoVersion:   "go1.22.3",
+		Hostname:    "host2, east",
+		ProcID:      "p2",
+		InitTime:    init,
+		CaptureTime: init.Add(2 * time.Hour),
+		Profile:     "goroutine",
+		SampleType:  "goroutine",
+		Value:       big.NewInt(7),
+	}}
+
+	const header = "dir,main,revision,go_version,hostname,proc_id,init_time,capture_time,profile,sample_type,value,base_dir,peer_score,history_score,base_value,ratio\n"
+	const row1 = "host1/b1,example.com/cmd/server,abc123,go1.22.3,host1,p1,2024-06-01T11:00:00Z,2024-06-01T12:00:00Z,goroutine,goroutine,300,,,,200,1.5\n"
+	const row2 = `host2/b1,example.com/cmd/server,abc123,go1.22.3,"host2, east",p2,2024-06-01T11:00:00Z,2024-06-01T13:00:00Z,goroutine,goroutine,7,,,,,` + "\n"
+
+	const json1 = `{"dir":"host1/b1","main":"example.com/cmd/server","revision":"abc123","go_version":"go1.22.3","hostname":"host1","proc_id":"p1","init_time":"2024-06-01T11:00:00Z","capture_time":"2024-06-01T12:00:00Z","profile":"goroutine","sample_type":"goroutine","value":300,"base_value":200,"ratio":1.5}` + "\n"
+	const json2 = `{"dir":"host2/b1","main":"example.com/cmd/server","revision":"abc123","go_version":"go1.22.3","hostname":"host2, east","proc_id":"p2","init_time":"2024-06-01T11:00:00Z","capture_time":"2024-06-01T13:00:00Z","profile":"goroutine","sample_type":"goroutine","value":7}` + "\n"
+
+	for _, tt := range []struct {
+		name   string
+		format string
+		recs   []*countRecord
+		want   string
+	}{
+		{"empty", "csv", nil, header},
+		{"single", "csv", recs[:1], header + row1},
+		{"multi", "csv", recs, header + row1 + row2},
+		{"empty", "jsonl", nil, ""},
+		{"single", "jsonl", recs[:1], json1},
+		{"multi", "jsonl", recs, json1 + json2},
+	} {
+		t.Run(tt.format+"/"+tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeRecords(&buf, tt.format, tt.recs)
+			if err != nil {
+				t.Fatalf("writeRecords; err = %v", err)
+			}
+			if have := buf.String(); have != tt.want {
+				t.Errorf("writeRecords:\n%s\nexpected:\n%s", have, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := writeRecords(&buf, "xml", recs)
+		if err == nil {
+			t.Errorf("writeRecords with unknown format; err = nil")
+		}
+	})
+}
+
+func TestWriteRecordsHeader(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeRecords[groupRecord](&buf, "csv", nil)
+	if err != nil {
+		t.Fatalf("writeRecords; err = %v", err)
+	}
+	want := "group_by,group,profile,sample_type,count,sum,mean,max,p50,p90,p99\n"
+	if have := buf.String(); have != want {
+		t.Errorf("writeRecords of no groups = %q, expected %q", have, want)
+	}
+}
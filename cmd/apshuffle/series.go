@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	svg "github.com/ajstarks/svgo"
+)
+
+const (
+	chartWidth      = 1000
+	chartHeight     = 500
+	chartMargin     = 60
+	chartTextSize   = "10px"
+	chartTextColor  = "grey"
+	chartAxisColor  = "lightgrey"
+	chartLineWidth  = 1
+	chartPointSize  = 2
+	chartLabelCount = 5
+)
+
+// chartColors is the palette for the lines of the time series chart. Each
+// process gets the next color, starting over at the beginning if there are
+// many processes.
+var chartColors = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// A series is the sequence of values from one process's bundles, in order of
+// their capture times.
+type series struct {
+	procID string
+	metas  []*meta
+	raw    []*big.Int
+	values []float64
+}
+
+// timeSeries groups the bundles by process. The series are in order of the
+// processes' first capture times.
+func timeSeries(values map[*meta]*big.Int) []*series {
+	byProc := make(map[string][]*meta)
+	for m := range values {
+		byProc[m.ProcID] = append(byProc[m.ProcID], m)
+	}
+
+	var out []*series
+	for procID, ms := range byProc {
+		sort.Slice(ms, func(i, j int) bool { return ms[i].CaptureTime.Before(ms[j].CaptureTime) })
+		s := &series{procID: procID, metas: ms}
+		for _, m := range ms {
+			f, _ := new(big.Float).SetInt(values[m]).Float64()
+			s.raw = append(s.raw, values[m])
+			s.values = append(s.values, f)
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ti, tj := out[i].metas[0].CaptureTime, out[j].metas[0].CaptureTime
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return out[i].procID < out[j].procID
+	})
+	return out
+}
+
+// renderTimeSeries draws a line chart with capture time on the horizontal
+// axis and the bundles' values on the vertical axis, with a line for each
+// process.
+func renderTimeSeries(all []*series, title string) []byte {
+	var (
+		minT, maxT time.Time
+		minV, maxV float64
+	)
+	for i, s := range all {
+		for j, m := range s.metas {
+			if (i == 0 && j == 0) || m.CaptureTime.Before(minT) {
+				minT = m.CaptureTime
+			}
+			if m.CaptureTime.After(maxT) {
+				maxT = m.CaptureTime
+			}
+			minV, maxV = min(minV, s.values[j]), max(maxV, s.values[j])
+		}
+	}
+	if maxV == minV {
+		maxV = minV + 1
+	}
+	widthNs := max(1, maxT.Sub(minT).Nanoseconds())
+
+	plotW, plotH := chartWidth-2*chartMargin, chartHeight-2*chartMargin
+	x := func(t time.Time) int {
+		return chartMargin + int(float64(t.Sub(minT).Nanoseconds())/float64(widthNs)*float64(plotW))
+	}
+	y := func(v float64) int {
+		return chartMargin + plotH - int((v-minV)/(maxV-minV)*float64(plotH))
+	}
+
+	var buf bytes.Buffer
+	img := svg.New(&buf)
+	img.Start(chartWidth, chartHeight)
+
+	text := func(x, y int, anchor string, s string) {
+		img.Text(x, y, s,
+			fmt.Sprintf("font-size=%q", chartTextSize),
+			fmt.Sprintf("fill=%q", chartTextColor),
+			fmt.Sprintf("text-anchor=%q", anchor))
+	}
+	axis := fmt.Sprintf("stroke=%q", chartAxisColor)
+
+	text(chartWidth/2, chartMargin/2, "middle", title)
+	for i := 0; i <= chartLabelCount; i++ {
+		v := minV + (maxV-minV)*float64(i)/chartLabelCount
+		img.Line(chartMargin, y(v), chartMargin+plotW, y(v), axis)
+		text(chartMargin-4, y(v), "end", fmt.Sprintf("%.4g", v))
+
+		t := minT.Add(time.Duration(float64(widthNs) * float64(i) / chartLabelCount))
+		img.Line(x(t), chartMargin, x(t), chartMargin+plotH, axis)
+		text(x(t), chartMargin+plotH+14, "middle", t.UTC().Format(time.RFC3339))
+	}
+
+	for i, s := range all {
+		color := chartColors[i%len(chartColors)]
+		img.Group(fmt.Sprintf("stroke=%q", color), fmt.Sprintf("fill=%q", color))
+		img.Title(s.procID)
+		var xs, ys []int
+		for j, m := range s.metas {
+			xs = append(xs, x(m.CaptureTime))
+			ys = append(ys, y(s.values[j]))
+		}
+		img.Polyline(xs, ys, "fill=\"none\"", fmt.Sprintf("stroke-width=\"%d\"", chartLineWidth))
+		for j, m := range s.metas {
+			img.Group()
+			img.Title(fmt.Sprintf("%s\n%s\n%v", m.dir, m.CaptureTime.UTC().Format(time.RFC3339), s.raw[j]))
+			img.Circle(xs[j], ys[j], chartPointSize)
+			img.Gend()
+		}
+		img.Gend()
+	}
+
+	img.End()
+	return buf.Bytes()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTimeSeries(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	values := make(map[*meta]*big.Int)
+	add := func(dir, procID string, capture time.Duration, v int64) {
+		values[&meta{dir: dir, ProcID: procID, CaptureTime: t0.Add(capture)}] = big.NewInt(v)
+	}
+	// Process "b" starts first, and "a" and "c" tie for second.
+	add("b/3", "b", 3*time.Minute, 30)
+	add("b/1", "b", 1*time.Minute, 10)
+	add("b/2", "b", 2*time.Minute, 20)
+	add("c/1", "c", 2*time.Minute, 7)
+	add("a/2", "a", 4*time.Minute, 5)
+	add("a/1", "a", 2*time.Minute, 6)
+
+	all := timeSeries(values)
+
+	type point struct {
+		dir string
+		raw int64
+		f   float64
+	}
+	var procs []string
+	var points [][]point
+	for _, s := range all {
+		procs = append(procs, s.procID)
+		var ps []point
+		for i, m := range s.metas {
+			if m.ProcID != s.procID {
+				t.Errorf("series %q includes bundle %q from process %q", s.procID, m.dir, m.ProcID)
+			}
+			ps = append(ps, point{m.dir, s.raw[i].Int64(), s.values[i]})
+		}
+		points = append(points, ps)
+	}
+	if want := []string{"b", "a", "c"}; !slices.Equal(procs, want) {
+		t.Errorf("timeSeries processes = %q, expected %q", procs, want)
+	}
+	want := [][]point{
+		{{"b/1", 10, 10}, {"b/2", 20, 20}, {"b/3", 30, 30}},
+		{{"a/1", 6, 6}, {"a/2", 5, 5}},
+		{{"c/1", 7, 7}},
+	}
+	if !slices.EqualFunc(points, want, slices.Equal) {
+		t.Errorf("timeSeries points = %v, expected %v", points, want)
+	}
+
+	svg := renderTimeSeries(all, "goroutine")
+	elements := make(map[string]int)
+	var titles []string
+	dec := xml.NewDecoder(bytes.NewReader(svg))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("SVG doesn't parse: %v\n%s", err, svg)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			elements[tok.Name.Local]++
+			if tok.Name.Local == "title" {
+				var text string
+				if err := dec.DecodeElement(&text, &tok); err != nil {
+					t.Fatalf("DecodeElement; err = %v", err)
+				}
+				titles = append(titles, text)
+			}
+		}
+	}
+	if have, want := elements["svg"], 1; have != want {
+		t.Errorf("SVG has %d svg elements, expected %d", have, want)
+	}
+	if have, want := elements["polyline"], len(all); have != want {
+		t.Errorf("SVG has %d lines, expected one for each of %d processes", have, want)
+	}
+	if have, want := elements["circle"], len(values); have != want {
+		t.Errorf("SVG has %d points, expected one for each of %d bundles", have, want)
+	}
+	if !slices.Contains(titles, "b") || !slices.Contains(titles, "b/2\n2024-06-01T12:02:00Z\n20") {
+		t.Errorf("SVG titles = %q, expected ones for the process and its points", titles)
+	}
+}
+
+func TestTimeSeriesSingle(t *testing.T) {
+	// A single bundle has no range of times or values to scale to, but still
+	// makes a chart.
+	m := &meta{dir: "a/1", ProcID: "a", CaptureTime: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	all := timeSeries(map[*meta]*big.Int{m: big.NewInt(0)})
+	if len(all) != 1 || len(all[0].metas) != 1 {
+		t.Fatalf("timeSeries of one bundle = %d series", len(all))
+	}
+	svg := renderTimeSeries(all, "heap")
+	dec := xml.NewDecoder(bytes.NewReader(svg))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("SVG doesn't parse: %v\n%s", err, svg)
+		}
+	}
+	if bytes.Contains(svg, []byte("NaN")) {
+		t.Errorf("SVG includes NaN:\n%s", svg)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// parseTagFilter compiles a -tagfocus or -tagignore value, following the
+// syntax of the flags of the same names in "go tool pprof":
+//
+//   - "key=value" matches samples with a "key" label that matches any of the
+//     comma-separated regexps in value.
+//   - "value" with no key matches samples where each of the comma-separated
+//     regexps in value matches one of the sample's labels, as "key:value".
+//   - When value looks like a number or a range of numbers (such as "32kb",
+//     ":64kb", "4mb:", "12kb:64mb", or "10ms:"), it matches the sample's
+//     numeric labels instead. Units of bytes and of time are converted as
+//     needed.
+func parseTagFilter(value string) (profile.TagMatch, error) {
+	var wantKey string
+	if key, v, ok := strings.Cut(value, "="); ok {
+		wantKey, value = key, v
+	}
+
+	if numFilter, ok := parseTagRange(value); ok {
+		match := func(s *profile.Sample, key string) bool {
+			for i, v := range s.NumLabel[key] {
+				var unit string
+				if units := s.NumUnit[key]; i < len(units) {
+					unit = units[i]
+				} else if key == "bytes" || key == "request" || key == "alignment" {
+					unit = "bytes"
+				}
+				if numFilter(v, unit) {
+					return true
+				}
+			}
+			return false
+		}
+		if wantKey == "" {
+			return func(s *profile.Sample) bool {
+				for key := range s.NumLabel {
+					if match(s, key) {
+						return true
+					}
+				}
+				return false
+			}, nil
+		}
+		return func(s *profile.Sample) bool { return match(s, wantKey) }, nil
+	}
+
+	var rxs []*regexp.Regexp
+	for _, expr := range strings.Split(value, ",") {
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		rxs = append(rxs, rx)
+	}
+	if wantKey == "" {
+		return func(s *profile.Sample) bool {
+		matched:
+			for _, rx := range rxs {
+				for key, vals := range s.Label {
+					for _, val := range vals {
+						if rx.MatchString(key + ":" + val) {
+							continue matched
+						}
+					}
+				}
+				return false
+			}
+			return true
+		}, nil
+	}
+	return func(s *profile.Sample) bool {
+		for _, rx := range rxs {
+			for _, val := range s.Label[wantKey] {
+				if rx.MatchString(val) {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+var tagRangeRx = regexp.MustCompile(`^([+-]?[0-9]+)([[:alpha:]µ]*)$`)
+
+// unitScales converts units of bytes and of time into bytes and nanoseconds.
+// Units in the same family share a base.
+var unitScales = map[string]struct {
+	base  string
+	scale int64
+}{
+	"b": {"bytes", 1}, "byte": {"bytes", 1}, "bytes": {"bytes", 1},
+	"kb": {"bytes", 1 << 10}, "kib": {"bytes", 1 << 10},
+	"mb": {"bytes", 1 << 20}, "mib": {"bytes", 1 << 20},
+	"gb": {"bytes", 1 << 30}, "gib": {"bytes", 1 << 30},
+	"tb": {"bytes", 1 << 40}, "tib": {"bytes", 1 << 40},
+	"ns": {"ns", 1}, "nanosecond": {"ns", 1}, "nanoseconds": {"ns", 1},
+	"us": {"ns", 1e3}, "µs": {"ns", 1e3}, "microsecond": {"ns", 1e3}, "microseconds": {"ns", 1e3},
+	"ms": {"ns", 1e6}, "millisecond": {"ns", 1e6}, "milliseconds": {"ns", 1e6},
+	"s": {"ns", 1e9}, "sec": {"ns", 1e9}, "second": {"ns", 1e9}, "seconds": {"ns", 1e9},
+	"m": {"ns", 60e9}, "min": {"ns", 60e9}, "minute": {"ns", 60e9}, "minutes": {"ns", 60e9},
+	"h": {"ns", 3600e9}, "hour": {"ns", 3600e9}, "hours": {"ns", 3600e9},
+}
+
+// parseTagRange interprets "N", "N:", ":N", and "N:M", where each number may
+// have a unit. It reports false if filter isn't in that form.
+func parseTagRange(filter string) (func(v int64, unit string) bool, bool) {
+	lo, hi, isRange := strings.Cut(filter, ":")
+	if !isRange {
+		hi = lo
+	}
+	if lo == "" && hi == "" {
+		return nil, false
+	}
+
+	type bound struct {
+		set   bool
+		value int64
+		base  string
+	}
+	parse := func(s string) (bound, bool) {
+		if s == "" {
+			return bound{}, true
+		}
+		m := tagRangeRx.FindStringSubmatch(s)
+		if m == nil {
+			return bound{}, false
+		}
+		v, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return bound{}, false
+		}
+		if m[2] == "" {
+			return bound{set: true, value: v}, true
+		}
+		u, ok := unitScales[strings.ToLower(m[2])]
+		if !ok {
+			return bound{}, false
+		}
+		return bound{set: true, value: v * u.scale, base: u.base}, true
+	}
+	from, ok := parse(lo)
+	if !ok {
+		return nil, false
+	}
+	to, ok := parse(hi)
+	if !ok {
+		return nil, false
+	}
+	if from.set && to.set && from.base != to.base {
+		return nil, false
+	}
+	base := from.base
+	if base == "" {
+		base = to.base
+	}
+
+	return func(v int64, unit string) bool {
+		if base != "" {
+			u, ok := unitScales[strings.ToLower(unit)]
+			if !ok || u.base != base {
+				return false
+			}
+			v *= u.scale
+		}
+		return (!from.set || v >= from.value) && (!to.set || v <= to.value)
+	}, true
+}
+
+// tagFilterFlag returns a flag.Func callback that sets match to the compiled
+// filter, and text to its source for use in the cache key.
+func tagFilterFlag(name string, match *profile.TagMatch, text *string) func(string) error {
+	return func(s string) error {
+		if *match != nil {
+			return fmt.Errorf("this flag may only be provided once")
+		}
+		m, err := parseTagFilter(s)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", name, err)
+		}
+		*match, *text = m, s
+		return nil
+	}
+}
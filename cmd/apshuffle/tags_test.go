@@ -0,0 +1,150 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestParseTagRange(t *testing.T) {
+	type value struct {
+		v    int64
+		unit string
+		want bool
+	}
+	testcase := func(filter string, values ...value) func(t *testing.T) {
+		return func(t *testing.T) {
+			match, ok := parseTagRange(filter)
+			if !ok {
+				t.Fatalf("parseTagRange(%q); ok = false", filter)
+			}
+			for _, v := range values {
+				if have := match(v.v, v.unit); have != v.want {
+					t.Errorf("parseTagRange(%q)(%d, %q) = %t, want %t", filter, v.v, v.unit, have, v.want)
+				}
+			}
+		}
+	}
+	badcase := func(filter string) func(t *testing.T) {
+		return func(t *testing.T) {
+			if _, ok := parseTagRange(filter); ok {
+				t.Errorf("parseTagRange(%q); ok = true", filter)
+			}
+		}
+	}
+
+	t.Run("", testcase("32kb",
+		value{32 << 10, "bytes", true},
+		value{32<<10 - 1, "bytes", false},
+		value{32, "kb", true},
+		value{32, "KiB", true},
+	))
+	t.Run("", testcase("4mb:",
+		value{4 << 20, "bytes", true},
+		value{4<<20 - 1, "bytes", false},
+		value{1, "gb", true},
+		value{1 << 30, "B", true},
+	))
+	t.Run("", testcase(":64kb",
+		value{0, "bytes", true},
+		value{64 << 10, "bytes", true},
+		value{65 << 10, "bytes", false},
+		value{1, "mb", false},
+	))
+	t.Run("", testcase("12kb:64mb",
+		value{11 << 10, "bytes", false},
+		value{12 << 10, "bytes", true},
+		value{64 << 20, "bytes", true},
+		value{64<<20 + 1, "bytes", false},
+	))
+	t.Run("", testcase("10ms:",
+		value{10e6, "ns", true},
+		value{9, "ms", false},
+		value{1, "s", true},
+		value{10000, "us", true},
+	))
+	t.Run("", testcase("10",
+		value{10, "", true},
+		value{11, "", false},
+		value{10, "bytes", true},
+	))
+
+	// Values in other units, or with no known unit, don't match a range with
+	// units.
+	t.Run("", testcase("1kb:",
+		value{2000, "ns", false},
+		value{2000, "", false},
+		value{2000, "furlongs", false},
+	))
+
+	t.Run("", badcase(""))
+	t.Run("", badcase(":"))
+	t.Run("", badcase("abc"))
+	t.Run("", badcase("1kb:1s"))
+	t.Run("", badcase("1parsec"))
+	t.Run("", badcase("1.5kb"))
+}
+
+func TestParseTagFilter(t *testing.T) {
+	type sample struct {
+		label    map[string][]string
+		numLabel map[string][]int64
+		numUnit  map[string][]string
+		want     bool
+	}
+	testcase := func(filter string, samples ...sample) func(t *testing.T) {
+		return func(t *testing.T) {
+			match, err := parseTagFilter(filter)
+			if err != nil {
+				t.Fatalf("parseTagFilter(%q); err = %v", filter, err)
+			}
+			for _, s := range samples {
+				ps := &profile.Sample{Label: s.label, NumLabel: s.numLabel, NumUnit: s.numUnit}
+				if have := match(ps); have != s.want {
+					t.Errorf("parseTagFilter(%q)(%v %v %v) = %t, want %t", filter, s.label, s.numLabel, s.numUnit, have, s.want)
+				}
+			}
+		}
+	}
+
+	// With a key, any of the regexps may match that label's value.
+	t.Run("", testcase("endpoint=^/api,^/health$",
+		sample{label: map[string][]string{"endpoint": {"/api/users"}}, want: true},
+		sample{label: map[string][]string{"endpoint": {"/health"}}, want: true},
+		sample{label: map[string][]string{"endpoint": {"/healthz"}}, want: false},
+		sample{label: map[string][]string{"path": {"/api/users"}}, want: false},
+		sample{want: false},
+	))
+
+	// Without a key, each regexp must match one of the labels as "key:value".
+	t.Run("", testcase("endpoint:/api,method:GET",
+		sample{label: map[string][]string{"endpoint": {"/api"}, "method": {"GET"}}, want: true},
+		sample{label: map[string][]string{"endpoint": {"/api"}, "method": {"POST"}}, want: false},
+		sample{label: map[string][]string{"endpoint": {"/api"}}, want: false},
+	))
+
+	// Numeric labels use their units, or "bytes" for the labels that the
+	// runtime's memory profiles use.
+	t.Run("", testcase("bytes=1kb:",
+		sample{numLabel: map[string][]int64{"bytes": {2048}}, want: true},
+		sample{numLabel: map[string][]int64{"bytes": {512}}, want: false},
+		sample{numLabel: map[string][]int64{"bytes": {512, 4096}}, want: true},
+		sample{numLabel: map[string][]int64{"size": {2048}}, want: false},
+	))
+	t.Run("", testcase("10ms:",
+		sample{numLabel: map[string][]int64{"wait": {20e6}}, numUnit: map[string][]string{"wait": {"ns"}}, want: true},
+		sample{numLabel: map[string][]int64{"wait": {5e6}}, numUnit: map[string][]string{"wait": {"ns"}}, want: false},
+		sample{numLabel: map[string][]int64{"wait": {20e6}}, want: false},
+	))
+	t.Run("", testcase("wait=:1s",
+		sample{numLabel: map[string][]int64{"wait": {20}}, numUnit: map[string][]string{"wait": {"ms"}}, want: true},
+		sample{numLabel: map[string][]int64{"wait": {2}}, numUnit: map[string][]string{"wait": {"s"}}, want: false},
+		sample{numLabel: map[string][]int64{"bytes": {20}}, want: false},
+	))
+
+	for _, filter := range []string{"endpoint=[", "["} {
+		if _, err := parseTagFilter(filter); err == nil {
+			t.Errorf("parseTagFilter(%q); err = nil", filter)
+		}
+	}
+}
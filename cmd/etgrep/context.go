@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/trace"
+)
+
+// A traceTimeFlag is a point in an execution trace, either as a timestamp or
+// as an offset from the start of the trace.
+type traceTimeFlag struct {
+	set      bool
+	relative bool
+	ts       trace.Time
+	offset   time.Duration
+}
+
+func (f *traceTimeFlag) String() string {
+	switch {
+	case f == nil || !f.set:
+		return ""
+	case f.relative:
+		return f.offset.String()
+	}
+	return strconv.FormatInt(int64(f.ts), 10)
+}
+
+func (f *traceTimeFlag) Set(s string) error {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*f = traceTimeFlag{set: true, ts: trace.Time(v)}
+		return nil
+	}
+	d, err := time.ParseDuration(strings.TrimPrefix(s, "+"))
+	if err != nil {
+		return fmt.Errorf("not a timestamp or a duration: %q", s)
+	}
+	*f = traceTimeFlag{set: true, relative: true, offset: d}
+	return nil
+}
+
+// resolve returns the timestamp that the flag describes, for a trace that
+// starts at start. It returns zero if the flag isn't set.
+func (f *traceTimeFlag) resolve(start trace.Time) trace.Time {
+	switch {
+	case !f.set:
+		return 0
+	case f.relative:
+		return start + trace.Time(f.offset)
+	}
+	return f.ts
+}
+
+// A contextBlock is a run of events from one goroutine that includes one or
+// more matching events, plus the events around them.
+type contextBlock struct {
+	goid       trace.GoID
+	start, end int // positions in the goroutine's list of events
+	events     []int
+}
+
+// contextBlocks finds the events within before and after events of each
+// matching event on the same goroutine. Blocks that overlap or touch are
+// merged, and the blocks are in the order of their first matching event.
+func (c *config) contextBlocks(order []int, before, after int) []*contextBlock {
+	byG := make(map[trace.GoID][]int)
+	pos := make([]int, len(c.events))
+	for i, ev := range c.events {
+		goid := eventGoroutine(ev)
+		pos[i] = len(byG[goid])
+		byG[goid] = append(byG[goid], i)
+	}
+
+	var blocks []*contextBlock
+	last := make(map[trace.GoID]*contextBlock)
+	for _, i := range order {
+		if !c.matches(i) {
+			continue
+		}
+		goid := eventGoroutine(c.events[i])
+		start := max(0, pos[i]-before)
+		end := min(len(byG[goid]), pos[i]+after+1)
+		if b := last[goid]; b != nil && start <= b.end {
+			b.end = max(b.end, end)
+			continue
+		}
+		b := &contextBlock{goid: goid, start: start, end: end}
+		blocks = append(blocks, b)
+		last[goid] = b
+	}
+
+	for _, b := range blocks {
+		b.events = byG[b.goid][b.start:b.end]
+	}
+	return blocks
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/trace"
+)
+
+func TestTraceTimeFlag(t *testing.T) {
+	const start trace.Time = 1_000_000_000
+	for _, tt := range []struct {
+		in   string
+		want trace.Time
+		str  string
+		bad  bool
+	}{
+		// A bare number is a timestamp, not an offset.
+		{in: "5", want: 5, str: "5"},
+		{in: "4947251492544", want: 4947251492544, str: "4947251492544"},
+		{in: "1.5s", want: start + trace.Time(1500*time.Millisecond), str: "1.5s"},
+		{in: "+250ms", want: start + trace.Time(250*time.Millisecond), str: "250ms"},
+		{in: "5ns", want: start + 5, str: "5ns"},
+		{in: "0s", want: start, str: "0s"},
+		{in: "1.5", bad: true},
+		{in: "5x", bad: true},
+		{in: "", bad: true},
+	} {
+		var f traceTimeFlag
+		err := f.Set(tt.in)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("Set(%q); err = nil", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Set(%q); err = %v", tt.in, err)
+			continue
+		}
+		if have := f.resolve(start); have != tt.want {
+			t.Errorf("Set(%q).resolve(%d) = %d, expected %d", tt.in, start, have, tt.want)
+		}
+		if have := f.String(); have != tt.str {
+			t.Errorf("Set(%q).String() = %q, expected %q", tt.in, have, tt.str)
+		}
+	}
+
+	var unset traceTimeFlag
+	if have := unset.resolve(start); have != 0 {
+		t.Errorf("unset flag resolves to %d, expected 0", have)
+	}
+}
+
+func TestContextBlocks(t *testing.T) {
+	evs := loadEvents(t)
+
+	// Goroutine 21 has six events: it starts, waits to receive, runs, waits
+	// to send, runs, and exits. Goroutines 7 and 20 exit between its first
+	// and second waits, and goroutine 1 goes to sleep between its second
+	// wait and its exit.
+	const (
+		waiting  = `StateTransition Running->Waiting "**"`
+		notExist = `StateTransition Running->NotExist`
+	)
+	const since, until = 4947251647000, 4947251658561
+
+	for _, tt := range []struct {
+		name          string
+		match         []string
+		goroutine     trace.GoID
+		since, until  trace.Time
+		byGoroutine   bool
+		before, after int
+		want          []string // goroutine and range of positions in its events
+	}{{
+		name:      "separate",
+		match:     []string{waiting},
+		goroutine: 21,
+		want:      []string{"G21 1:2", "G21 3:4"},
+	}, {
+		name:      "touching before",
+		match:     []string{waiting},
+		goroutine: 21,
+		before:    1,
+		want:      []string{"G21 0:4"},
+	}, {
+		name:      "touching after",
+		match:     []string{waiting},
+		goroutine: 21,
+		after:     1,
+		want:      []string{"G21 1:5"},
+	}, {
+		name:      "overlapping",
+		match:     []string{waiting, notExist},
+		goroutine: 21,
+		before:    1,
+		after:     1,
+		want:      []string{"G21 0:6"},
+	}, {
+		name:      "clamp at start",
+		match:     []string{waiting},
+		goroutine: 21,
+		before:    5,
+		want:      []string{"G21 0:4"},
+	}, {
+		name:      "clamp at end",
+		match:     []string{notExist},
+		goroutine: 21,
+		before:    2,
+		after:     3,
+		want:      []string{"G21 3:6"},
+	}, {
+		name:  "time order",
+		match: []string{waiting, notExist},
+		since: since, until: until,
+		want: []string{"G7 1:2", "G20 3:4", "G21 3:4", "G1 29:30", "G21 5:6"},
+	}, {
+		// The block for goroutine 21 starts before goroutine 1's block, and
+		// stays there when it grows to include a later match.
+		name:  "first match order",
+		match: []string{waiting, notExist},
+		since: since, until: until,
+		after: 1,
+		want:  []string{"G7 1:2", "G20 3:4", "G21 3:6", "G1 29:31"},
+	}, {
+		name:  "goroutine order",
+		match: []string{waiting, notExist},
+		since: since, until: until,
+		byGoroutine: true,
+		want:        []string{"G1 29:30", "G7 1:2", "G20 3:4", "G21 3:4", "G21 5:6"},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config{events: evs, filterGoID: tt.goroutine, since: tt.since, until: tt.until}
+			for _, m := range tt.match {
+				if err := c.match.Set(m); err != nil {
+					t.Fatalf("match.Set(%q); err = %v", m, err)
+				}
+			}
+			order := make([]int, len(evs))
+			for i := range order {
+				order[i] = i
+			}
+			if tt.byGoroutine {
+				sort.SliceStable(order, func(i, j int) bool {
+					return eventGoroutine(evs[order[i]]) < eventGoroutine(evs[order[j]])
+				})
+			}
+
+			var have []string
+			for _, b := range c.contextBlocks(order, tt.before, tt.after) {
+				have = append(have, fmt.Sprintf("G%d %d:%d", b.goid, b.start, b.end))
+				if len(b.events) != b.end-b.start {
+					t.Errorf("block G%d %d:%d has %d events", b.goid, b.start, b.end, len(b.events))
+				}
+				for k, i := range b.events {
+					if g := eventGoroutine(evs[i]); g != b.goid {
+						t.Errorf("block for G%d includes event %d from G%d", b.goid, i, g)
+					}
+					if k > 0 && i <= b.events[k-1] {
+						t.Errorf("block G%d events out of order: %d", b.goid, b.events)
+					}
+				}
+			}
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("contextBlocks = %q, expected %q", have, tt.want)
+			}
+		})
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"strings"
 
 	"github.com/rhysh/go-tracing-toolbox/internal/flag2"
-	"github.com/rhysh/go-tracing-toolbox/internal/match2"
 	"golang.org/x/exp/trace"
 )
 
@@ -21,20 +20,43 @@ func main() {
 	showStacks := flag.Bool("stacks", false, "Show full stack of matching events")
 	goroutine := flag.Int64("goroutine", 0, "Filter to events from a single goroutine")
 	timestamp := flag.Int64("time", 0, "Filter to events with a specific timestamp")
+	var since, until traceTimeFlag
+	flag.Var(&since, "since", `Filter to events at or after this timestamp (a bare number, as in "Time="), or this long after the start of the trace (a duration with a unit, such as "1.5s")`)
+	flag.Var(&until, "until", `Filter to events before this timestamp (a bare number), or this long after the start of the trace (a duration with a unit)`)
+	before := flag.Int("B", 0, "Show this many events before each matching event on the same goroutine")
+	after := flag.Int("A", 0, "Show this many events after each matching event on the same goroutine")
+	format := flag.String("format", "text", `Output format: "text", or "jsonl" for JSON lines`)
+	profFile := flag.String("profile", "", "Instead of printing the matching events, write them to this pprof profile, with their counts and how long each stopped its goroutine from running")
 	sortBy := flag.String("sort", "time", `Sort by "time" or "goroutine"`)
+	showLinks := flag.Bool("links", false, "Show which event made each goroutine runnable, and where each goroutine that an event made runnable started running")
+	showChain := flag.Bool("chain", false, "After each matching event, show the chain of events that made its goroutine runnable, and those that made each of theirs runnable")
 
-	var match flag2.StackFlag
+	var match, exclude flag2.StackFlagList
 	flag.Var(&match, "match", `
 Event and stack pattern to match. Try 'Any "**"' to match all events.
 Try 'StateTransition "net/http...conn..serve" "ServeHTTP" "**" "sync...Mutex..Lock"'
-to find stacks where an inbound HTTP request has to wait for a Mutex.`[1:])
+to find stacks where an inbound HTTP request has to wait for a Mutex.
+StateTransition patterns may also name the goroutine states and the reason,
+as in 'StateTransition Running->Waiting reason="sync" "**"'.
+May be repeated, to show events that match any of the patterns.`[1:])
+	flag.Var(&exclude, "exclude", "Event and stack pattern, in the format of -match, of events to hide (may be repeated)")
 
 	flag.Parse()
 
+	switch *format {
+	case "text", "jsonl":
+	default:
+		log.Fatalf("Unknown -format value %q", *format)
+	}
+
 	cfg := &config{
 		sort:       *sortBy,
 		showStacks: *showStacks,
-		match:      &match,
+		showLinks:  *showLinks,
+		showChain:  *showChain,
+		jsonl:      *format == "jsonl",
+		match:      match,
+		exclude:    exclude,
 		filterGoID: trace.GoID(*goroutine),
 		filterTime: trace.Time(*timestamp),
 	}
@@ -50,80 +72,190 @@ to find stacks where an inbound HTTP request has to wait for a Mutex.`[1:])
 		log.Fatalf("trace.NewReader: %v", err)
 	}
 
-	var timeEvents []trace.Event
+	// Sorting by goroutine, following links, showing context, and writing a
+	// profile all need the whole trace. Otherwise, print each matching event
+	// as soon as it's read.
+	streaming := cfg.sort == "time" && !cfg.showLinks && !cfg.showChain &&
+		*before == 0 && *after == 0 && *profFile == ""
+	if streaming {
+		cfg.events = make([]trace.Event, 1)
+	}
 
-	for {
+	var events []trace.Event
+	for n := 0; ; n++ {
 		ev, err := reader.ReadEvent()
 		if err == io.EOF {
 			break
 		}
-
-		if cfg.sort == "time" {
-			str := cfg.printableString(ev)
-			if str != "" {
-				fmt.Printf("%s", str)
-			}
-		} else {
-			timeEvents = append(timeEvents, ev)
+		if err != nil {
+			log.Fatalf("ReadEvent: %v", err)
+		}
+		if n == 0 {
+			start := ev.Time()
+			cfg.since, cfg.until = since.resolve(start), until.resolve(start)
+		}
+		if streaming {
+			cfg.events[0] = ev
+			fmt.Printf("%s", cfg.printableString(0))
+			continue
 		}
+		events = append(events, ev)
 	}
+	if streaming {
+		return
+	}
+	cfg.events = events
 
-	// TODO: to/from event links
+	if cfg.showLinks || cfg.showChain {
+		cfg.links = findLinks(events)
+	}
 
-	if *sortBy == "goroutine" {
-		goroutineEvents := make(map[trace.GoID][]trace.Event)
-		var goroutineList []trace.GoID
-		for _, ev := range timeEvents {
-			goid := eventGoroutine(ev)
-			goroutineEvents[goid] = append(goroutineEvents[goid], ev)
-		}
-		for goid := range goroutineEvents {
-			goroutineList = append(goroutineList, goid)
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
+	}
+	if cfg.sort == "goroutine" {
+		sort.SliceStable(order, func(i, j int) bool {
+			return eventGoroutine(events[order[i]]) < eventGoroutine(events[order[j]])
+		})
+	}
+
+	if *profFile != "" {
+		err := cfg.writeProfile(*profFile, order)
+		if err != nil {
+			log.Fatalf("writeProfile: %v", err)
 		}
-		sort.Slice(goroutineList, func(i, j int) bool { return goroutineList[i] < goroutineList[j] })
-
-		var prevG trace.GoID
-		for _, goid := range goroutineList {
-			evs := goroutineEvents[goid]
-			for _, ev := range evs {
-				str := cfg.printableString(ev)
-				if str != "" {
-					if prevG != goid {
-						if prevG != 0 {
-							fmt.Printf("\n")
-						}
-						prevG = goid
-					}
-					fmt.Printf("%s", str)
+		return
+	}
+
+	if *before > 0 || *after > 0 {
+		for n, b := range cfg.contextBlocks(order, *before, *after) {
+			if n > 0 && !cfg.jsonl {
+				fmt.Printf("--\n")
+			}
+			for _, i := range b.events {
+				if cfg.matches(i) {
+					fmt.Printf("%s", cfg.describe(i))
+				} else {
+					fmt.Printf("%s", cfg.describeContext(i))
 				}
 			}
 		}
+		return
+	}
+
+	first := true
+	var prevG trace.GoID
+	for _, i := range order {
+		str := cfg.printableString(i)
+		if str == "" {
+			continue
+		}
+		if goid := eventGoroutine(events[i]); cfg.sort == "goroutine" && goid != prevG {
+			if !first && !cfg.jsonl {
+				fmt.Printf("\n")
+			}
+			prevG = goid
+		}
+		first = false
+		fmt.Printf("%s", str)
 	}
 }
 
 type config struct {
 	sort       string
 	showStacks bool
-	match      *flag2.StackFlag
+	showLinks  bool
+	showChain  bool
+	jsonl      bool
+	match      flag2.StackFlagList
+	exclude    flag2.StackFlagList
 	filterGoID trace.GoID
 	filterTime trace.Time
+	since      trace.Time // zero for no limit
+	until      trace.Time // zero for no limit
+
+	events []trace.Event
+	links  *eventLinks
 }
 
-func (c *config) printableString(ev trace.Event) string {
-	if c.filterGoID != 0 && c.filterGoID != eventGoroutine(ev) {
+func (c *config) printableString(i int) string {
+	if !c.matches(i) {
 		return ""
 	}
+	return c.describe(i)
+}
+
+func (c *config) matches(i int) bool {
+	ev := c.events[i]
+	if c.filterGoID != 0 && c.filterGoID != eventGoroutine(ev) {
+		return false
+	}
 	if c.filterTime != 0 && c.filterTime != ev.Time() {
-		return ""
+		return false
+	}
+	if c.since != 0 && ev.Time() < c.since {
+		return false
+	}
+	if c.until != 0 && ev.Time() >= c.until {
+		return false
+	}
+	if len(c.match) > 0 || len(c.exclude) > 0 {
+		stk := eventStack(ev)
+		if len(c.match) > 0 && !c.match.Any(ev, stk) {
+			return false
+		}
+		if c.exclude.Any(ev, stk) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe formats a matching event, followed by its chain of wakeups when
+// requested.
+func (c *config) describe(i int) string {
+	if c.jsonl {
+		return c.jsonLine(i, false)
 	}
-	if c.match.Event != 0 || c.match.Specs != nil {
-		if !c.match.EventMatches(ev.Kind()) || !match2.HasStackRe(eventStack(ev), c.match.Specs...) {
-			return ""
+	str := new(strings.Builder)
+	fmt.Fprintf(str, "%s", c.format(i))
+	if c.showChain {
+		for _, w := range c.links.chain(i) {
+			fmt.Fprintf(str, "<- %s", c.format(w))
+		}
+	}
+	return str.String()
+}
+
+// describeContext formats an event that -A or -B shows near a matching event.
+func (c *config) describeContext(i int) string {
+	if c.jsonl {
+		return c.jsonLine(i, true)
+	}
+	return c.format(i)
+}
+
+// format describes event i, including its links and stack when requested.
+func (c *config) format(i int) string {
+	ev := c.events[i]
+
+	var links []string
+	if c.showLinks {
+		if from, ok := c.links.from[i]; ok {
+			links = append(links, fmt.Sprintf("from %s", eventString(c.events[from])))
+		}
+		if to, ok := c.links.to[i]; ok {
+			links = append(links, fmt.Sprintf("to %s", eventString(c.events[to])))
 		}
 	}
 
 	str := new(strings.Builder)
-	fmt.Fprintf(str, "%s\n", eventString(ev))
+	fmt.Fprintf(str, "%s", eventString(ev))
+	if len(links) > 0 {
+		fmt.Fprintf(str, " (%s)", strings.Join(links, ", "))
+	}
+	fmt.Fprintf(str, "\n")
 	if c.showStacks {
 		fmt.Fprintf(str, "%s", stackString(eventStack(ev)))
 	}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"golang.org/x/exp/trace"
+)
+
+// testTrace is a short execution trace of a program that exercises the
+// scheduler in several ways. See its README for details.
+const testTrace = "../../testdata/go1.27.1/trace_events/events.trace"
+
+func loadEvents(t *testing.T) []trace.Event {
+	t.Helper()
+	f, err := os.Open(testTrace)
+	if err != nil {
+		t.Fatalf("Open; err = %v", err)
+	}
+	defer f.Close()
+	r, err := trace.NewReader(bufio.NewReader(f))
+	if err != nil {
+		t.Fatalf("trace.NewReader; err = %v", err)
+	}
+	var evs []trace.Event
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadEvent; err = %v", err)
+		}
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+// findTransition returns the index of the nth (from 0) event that moves
+// goroutine goid from one state to another.
+func findTransition(t *testing.T, evs []trace.Event, goid trace.GoID, from, to trace.GoState, n int) int {
+	t.Helper()
+	for i, ev := range evs {
+		if ev.Kind() != trace.EventStateTransition {
+			continue
+		}
+		st := ev.StateTransition()
+		if st.Resource.Kind != trace.ResourceGoroutine || st.Resource.Goroutine() != goid {
+			continue
+		}
+		if f, t := st.Goroutine(); f == from && t == to {
+			if n == 0 {
+				return i
+			}
+			n--
+		}
+	}
+	t.Fatalf("no %s->%s transition for goroutine %d", from, to, goid)
+	return -1
+}
+
+// transitionString describes a goroutine's state transition event, including
+// the goroutine that caused it.
+func transitionString(ev trace.Event) string {
+	st := ev.StateTransition()
+	from, to := st.Goroutine()
+	return fmt.Sprintf("G%d: G%d %s->%s", ev.Goroutine(), st.Resource.Goroutine(), from, to)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"golang.org/x/exp/trace"
+)
+
+// An eventRecord is the JSON form of an event, for -format=jsonl. Each kind of
+// event fills in its own detail field.
+type eventRecord struct {
+	Kind      string `json:"kind"`
+	Time      int64  `json:"time"`
+	Goroutine int64  `json:"goroutine"`
+	Proc      int64  `json:"proc"`
+	Thread    int64  `json:"thread"`
+	// Context marks the events that -A and -B show near matching events.
+	Context bool `json:"context,omitempty"`
+
+	StateTransition *stateRecord  `json:"state_transition,omitempty"`
+	Range           *rangeRecord  `json:"range,omitempty"`
+	Log             *logRecord    `json:"log,omitempty"`
+	Label           *labelRecord  `json:"label,omitempty"`
+	Metric          *metricRecord `json:"metric,omitempty"`
+	Task            *taskRecord   `json:"task,omitempty"`
+	Region          *regionRecord `json:"region,omitempty"`
+
+	Stack []frameRecord `json:"stack,omitempty"`
+
+	// From, To, and Chain are from -links and -chain.
+	From  *eventRecord   `json:"from,omitempty"`
+	To    *eventRecord   `json:"to,omitempty"`
+	Chain []*eventRecord `json:"chain,omitempty"`
+}
+
+type resourceRecord struct {
+	Kind string `json:"kind"`
+	ID   int64  `json:"id"`
+}
+
+type stateRecord struct {
+	Resource resourceRecord `json:"resource"`
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Reason   string         `json:"reason,omitempty"`
+	// Stack is the stack of the goroutine that changed state, which may be
+	// different from the stack of the event.
+	Stack []frameRecord `json:"stack,omitempty"`
+}
+
+type rangeRecord struct {
+	Name       string            `json:"name"`
+	Scope      resourceRecord    `json:"scope"`
+	Attributes []attributeRecord `json:"attributes,omitempty"`
+}
+
+type attributeRecord struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type logRecord struct {
+	Task     *uint64 `json:"task,omitempty"`
+	Category string  `json:"category"`
+	Message  string  `json:"message"`
+}
+
+type labelRecord struct {
+	Label    string         `json:"label"`
+	Resource resourceRecord `json:"resource"`
+}
+
+type metricRecord struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type taskRecord struct {
+	ID     *uint64 `json:"id,omitempty"`
+	Parent *uint64 `json:"parent,omitempty"`
+	Type   string  `json:"type"`
+}
+
+type regionRecord struct {
+	Task *uint64 `json:"task,omitempty"`
+	Type string  `json:"type"`
+}
+
+type frameRecord struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line uint64 `json:"line"`
+	PC   uint64 `json:"pc"`
+}
+
+// jsonLine encodes event i as a line of JSON, including its links and chain of
+// wakeups when requested.
+func (c *config) jsonLine(i int, context bool) string {
+	rec := c.record(i)
+	rec.Context = context
+	if c.showLinks {
+		if from, ok := c.links.from[i]; ok {
+			rec.From = c.record(from)
+		}
+		if to, ok := c.links.to[i]; ok {
+			rec.To = c.record(to)
+		}
+	}
+	if c.showChain && !context {
+		for _, w := range c.links.chain(i) {
+			rec.Chain = append(rec.Chain, c.record(w))
+		}
+	}
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		log.Fatalf("json.Marshal: %v", err)
+	}
+	return string(buf) + "\n"
+}
+
+func (c *config) record(i int) *eventRecord {
+	ev := c.events[i]
+	rec := &eventRecord{
+		Kind:      ev.Kind().String(),
+		Time:      int64(ev.Time()),
+		Goroutine: int64(ev.Goroutine()),
+		Proc:      int64(ev.Proc()),
+		Thread:    int64(ev.Thread()),
+	}
+	if c.showStacks {
+		rec.Stack = stackRecord(ev.Stack())
+	}
+
+	switch ev.Kind() {
+	case trace.EventStateTransition:
+		st := ev.StateTransition()
+		sr := &stateRecord{Resource: resource(st.Resource), Reason: st.Reason}
+		switch st.Resource.Kind {
+		case trace.ResourceGoroutine:
+			from, to := st.Goroutine()
+			sr.From, sr.To = from.String(), to.String()
+		case trace.ResourceProc:
+			from, to := st.Proc()
+			sr.From, sr.To = from.String(), to.String()
+		}
+		if c.showStacks {
+			sr.Stack = stackRecord(st.Stack)
+		}
+		rec.StateTransition = sr
+	case trace.EventRangeBegin, trace.EventRangeActive, trace.EventRangeEnd:
+		r := ev.Range()
+		rr := &rangeRecord{Name: r.Name, Scope: resource(r.Scope)}
+		if ev.Kind() == trace.EventRangeEnd {
+			for _, attr := range ev.RangeAttributes() {
+				rr.Attributes = append(rr.Attributes, attributeRecord{Name: attr.Name, Value: value(attr.Value)})
+			}
+		}
+		rec.Range = rr
+	case trace.EventLog:
+		l := ev.Log()
+		rec.Log = &logRecord{Task: taskID(l.Task), Category: l.Category, Message: l.Message}
+	case trace.EventLabel:
+		l := ev.Label()
+		rec.Label = &labelRecord{Label: l.Label, Resource: resource(l.Resource)}
+	case trace.EventMetric:
+		m := ev.Metric()
+		rec.Metric = &metricRecord{Name: m.Name, Value: value(m.Value)}
+	case trace.EventTaskBegin, trace.EventTaskEnd:
+		t := ev.Task()
+		rec.Task = &taskRecord{ID: taskID(t.ID), Parent: taskID(t.Parent), Type: t.Type}
+	case trace.EventRegionBegin, trace.EventRegionEnd:
+		r := ev.Region()
+		rec.Region = &regionRecord{Task: taskID(r.Task), Type: r.Type}
+	}
+	return rec
+}
+
+func resource(r trace.ResourceID) resourceRecord {
+	rec := resourceRecord{Kind: r.Kind.String(), ID: -1}
+	switch r.Kind {
+	case trace.ResourceGoroutine:
+		rec.ID = int64(r.Goroutine())
+	case trace.ResourceProc:
+		rec.ID = int64(r.Proc())
+	case trace.ResourceThread:
+		rec.ID = int64(r.Thread())
+	}
+	return rec
+}
+
+// value converts a trace value to a number or a string, or nil if it is of
+// an unknown kind.
+func value(v trace.Value) any {
+	switch v.Kind() {
+	case trace.ValueUint64:
+		return v.Uint64()
+	case trace.ValueString:
+		return v.String()
+	}
+	return nil
+}
+
+// taskID returns nil for NoTask.
+func taskID(id trace.TaskID) *uint64 {
+	if id == trace.NoTask {
+		return nil
+	}
+	v := uint64(id)
+	return &v
+}
+
+func stackRecord(stk trace.Stack) []frameRecord {
+	var frames []frameRecord
+	for f := range stk.Frames() {
+		frames = append(frames, frameRecord{Func: f.Func, File: f.File, Line: f.Line, PC: f.PC})
+	}
+	return frames
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/trace"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// TestJSONGolden checks the -format=jsonl schema, which scripts may depend
+// on. Run with -update to accept a change to it.
+func TestJSONGolden(t *testing.T) {
+	evs := loadEvents(t)
+	c := &config{events: evs, showStacks: true, showLinks: true, showChain: true, jsonl: true}
+	c.links = findLinks(evs)
+
+	find := func(ok func(trace.Event) bool) int {
+		t.Helper()
+		for i, ev := range evs {
+			if ok(ev) {
+				return i
+			}
+		}
+		t.Fatalf("no such event")
+		return -1
+	}
+
+	var buf bytes.Buffer
+	for _, tt := range []struct {
+		i       int
+		context bool
+	}{
+		// A log message in a task.
+		{i: find(func(ev trace.Event) bool {
+			return ev.Kind() == trace.EventLog && ev.Log().Message == "start"
+		})},
+		// Goroutine 1 wakes goroutine 7, which then runs: the links go both
+		// ways.
+		{i: findTransition(t, evs, 7, trace.GoWaiting, trace.GoRunnable, 0)},
+		{i: findTransition(t, evs, 7, trace.GoRunnable, trace.GoRunning, 0)},
+		// Goroutine 1 sleeps, with a stack for the transition as well as the
+		// event.
+		{i: findTransition(t, evs, 1, trace.GoRunning, trace.GoWaiting, 1)},
+		// A range with attributes.
+		{i: find(func(ev trace.Event) bool {
+			return ev.Kind() == trace.EventRangeEnd && len(ev.RangeAttributes()) > 0
+		})},
+		// The exit of goroutine 21, with its chain of wakeups, and as context
+		// without one.
+		{i: findTransition(t, evs, 21, trace.GoRunning, trace.GoNotExist, 0)},
+		{i: findTransition(t, evs, 21, trace.GoRunning, trace.GoNotExist, 0), context: true},
+	} {
+		buf.WriteString(c.jsonLine(tt.i, tt.context))
+	}
+
+	golden := filepath.Join("testdata", "events.jsonl")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("WriteFile; err = %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("ReadFile; err = %v", err)
+	}
+	have := strings.Split(buf.String(), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	if len(have) != len(wantLines) {
+		t.Fatalf("jsonLine wrote %d lines, expected %d", len(have), len(wantLines))
+	}
+	for i := range have {
+		if have[i] != wantLines[i] {
+			t.Errorf("line %d:\nhave %s\nwant %s", i+1, have[i], wantLines[i])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"golang.org/x/exp/trace"
+)
+
+// eventLinks connects the events that make goroutines runnable to the events
+// where those goroutines start running. Events are identified by their index
+// in the trace.
+type eventLinks struct {
+	// from maps a goroutine's transition to Running to the event that made
+	// the goroutine runnable, usually on another goroutine.
+	from map[int]int
+	// to is the reverse of from: it maps an event that made a goroutine
+	// runnable to the event where that goroutine started running.
+	to map[int]int
+	// running holds, for each event, the transition to Running that ended the
+	// most recent wait of the event's goroutine, or -1 if there isn't one in
+	// the trace. Preemptions and syscalls don't count as waits.
+	running []int
+}
+
+func findLinks(evs []trace.Event) *eventLinks {
+	l := &eventLinks{
+		from:    make(map[int]int),
+		to:      make(map[int]int),
+		running: make([]int, len(evs)),
+	}
+	woken := make(map[trace.GoID]int)
+	lastRun := make(map[trace.GoID]int)
+	for i, ev := range evs {
+		var stopped trace.GoID = trace.NoGoroutine
+		if ev.Kind() == trace.EventStateTransition {
+			st := ev.StateTransition()
+			if st.Resource.Kind == trace.ResourceGoroutine {
+				goid := st.Resource.Goroutine()
+				from, to := st.Goroutine()
+				switch {
+				case to == trace.GoRunnable && (from == trace.GoWaiting || from == trace.GoNotExist):
+					woken[goid] = i
+				case to == trace.GoRunning:
+					if w, ok := woken[goid]; ok {
+						l.from[i], l.to[w] = w, i
+						delete(woken, goid)
+						lastRun[goid] = i
+					} else if _, ok := lastRun[goid]; !ok {
+						lastRun[goid] = i
+					}
+				case from == trace.GoRunning && (to == trace.GoWaiting || to == trace.GoNotExist):
+					stopped = goid
+				}
+			}
+		}
+
+		l.running[i] = -1
+		if r, ok := lastRun[eventGoroutine(ev)]; ok {
+			l.running[i] = r
+		}
+		if stopped != trace.NoGoroutine {
+			delete(lastRun, stopped)
+		}
+	}
+	return l
+}
+
+// chain lists the events that led to event i, from the event that made its
+// goroutine runnable back through the events that made each of those
+// goroutines runnable in turn.
+func (l *eventLinks) chain(i int) []int {
+	var chain []int
+	for {
+		r := l.running[i]
+		if r < 0 {
+			return chain
+		}
+		w, ok := l.from[r]
+		if !ok {
+			return chain
+		}
+		chain = append(chain, w)
+		// Each step goes back in time, so the chain ends.
+		i = w
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"golang.org/x/exp/trace"
+)
+
+func TestLinks(t *testing.T) {
+	evs := loadEvents(t)
+	l := findLinks(evs)
+
+	chain := func(i int) []string {
+		var out []string
+		for _, w := range l.chain(i) {
+			out = append(out, transitionString(evs[w]))
+		}
+		return out
+	}
+
+	t.Run("wake", func(t *testing.T) {
+		// Goroutine 7 was waiting when the trace started. Goroutine 1 wakes it
+		// with a channel send.
+		wake := findTransition(t, evs, 7, trace.GoWaiting, trace.GoRunnable, 0)
+		run := findTransition(t, evs, 7, trace.GoRunnable, trace.GoRunning, 0)
+		if from, ok := l.from[run]; !ok || from != wake {
+			t.Errorf("from[%d] = %d, %t; expected %d", run, from, ok, wake)
+		}
+		if to, ok := l.to[wake]; !ok || to != run {
+			t.Errorf("to[%d] = %d, %t; expected %d", wake, to, ok, run)
+		}
+		if have, want := transitionString(evs[wake]), "G1: G7 Waiting->Runnable"; have != want {
+			t.Errorf("wake event is %q, expected %q", have, want)
+		}
+	})
+
+	t.Run("new goroutine", func(t *testing.T) {
+		// A new goroutine's first run links to the go statement that created
+		// it.
+		create := findTransition(t, evs, 20, trace.GoNotExist, trace.GoRunnable, 0)
+		run := findTransition(t, evs, 20, trace.GoRunnable, trace.GoRunning, 0)
+		exit := findTransition(t, evs, 20, trace.GoRunning, trace.GoNotExist, 0)
+		if from, ok := l.from[run]; !ok || from != create {
+			t.Errorf("from[%d] = %d, %t; expected %d", run, from, ok, create)
+		}
+		if l.running[exit] != run {
+			t.Errorf("running[%d] = %d, expected %d", exit, l.running[exit], run)
+		}
+		if have, want := chain(exit), []string{"G1: G20 NotExist->Runnable"}; !slices.Equal(have, want) {
+			t.Errorf("chain of goroutine 20's exit = %q, expected %q", have, want)
+		}
+	})
+
+	t.Run("running at start", func(t *testing.T) {
+		// Goroutine 1 was running when the trace started, so nothing in the
+		// trace made it runnable.
+		run := findTransition(t, evs, 1, trace.GoUndetermined, trace.GoRunning, 0)
+		if from, ok := l.from[run]; ok {
+			t.Errorf("from[%d] = %d, expected none", run, from)
+		}
+		block := findTransition(t, evs, 1, trace.GoRunning, trace.GoWaiting, 0)
+		if l.running[block] != run {
+			t.Errorf("running[%d] = %d, expected %d", block, l.running[block], run)
+		}
+		if have := chain(block); len(have) != 0 {
+			t.Errorf("chain of goroutine 1's first block = %q, expected none", have)
+		}
+	})
+
+	t.Run("preemption", func(t *testing.T) {
+		// Goroutine 22 is preempted while it runs. That doesn't end the run
+		// that its creation started.
+		create := findTransition(t, evs, 22, trace.GoNotExist, trace.GoRunnable, 0)
+		run := findTransition(t, evs, 22, trace.GoRunnable, trace.GoRunning, 0)
+		preempt := findTransition(t, evs, 22, trace.GoRunning, trace.GoRunnable, 0)
+		resume := findTransition(t, evs, 22, trace.GoRunnable, trace.GoRunning, 1)
+		exit := findTransition(t, evs, 22, trace.GoRunning, trace.GoNotExist, 0)
+		if reason := evs[preempt].StateTransition().Reason; reason != "preempted" {
+			t.Errorf("goroutine 22 stops running for %q, expected preemption", reason)
+		}
+		if from, ok := l.from[resume]; ok {
+			t.Errorf("from[%d] = %d after preemption, expected none", resume, from)
+		}
+		for _, i := range []int{preempt, resume, exit} {
+			if l.running[i] != run {
+				t.Errorf("running[%d] = %d, expected %d", i, l.running[i], run)
+			}
+		}
+		have := l.chain(exit)
+		if len(have) == 0 || have[0] != create {
+			t.Errorf("chain of goroutine 22's exit = %d, expected it to start at %d", have, create)
+		}
+	})
+
+	t.Run("syscall", func(t *testing.T) {
+		// After its sleep, goroutine 1 makes some quick syscalls, then a slow
+		// one that loses its P. None of those are waits, so its events until
+		// it next blocks on a channel still link to the timer that woke it.
+		wake := findTransition(t, evs, 1, trace.GoWaiting, trace.GoRunnable, 1)
+		run := findTransition(t, evs, 1, trace.GoRunnable, trace.GoRunning, 1)
+		if evs[wake].Goroutine() != trace.NoGoroutine {
+			t.Errorf("goroutine 1 woken by goroutine %d, expected the timer", evs[wake].Goroutine())
+		}
+		if from, ok := l.from[run]; !ok || from != wake {
+			t.Errorf("from[%d] = %d, %t; expected %d", run, from, ok, wake)
+		}
+		lost := findTransition(t, evs, 1, trace.GoSyscall, trace.GoRunnable, 0)
+		resume := findTransition(t, evs, 1, trace.GoRunnable, trace.GoRunning, 2)
+		if resume < lost {
+			t.Fatalf("goroutine 1 runs again at %d, before it loses its P at %d", resume, lost)
+		}
+		if from, ok := l.from[resume]; ok {
+			t.Errorf("from[%d] = %d after syscall, expected none", resume, from)
+		}
+		for _, i := range []int{
+			findTransition(t, evs, 1, trace.GoRunning, trace.GoSyscall, 0),
+			findTransition(t, evs, 1, trace.GoSyscall, trace.GoRunning, 0),
+			findTransition(t, evs, 1, trace.GoSyscall, trace.GoRunning, 2),
+			lost,
+			resume,
+			findTransition(t, evs, 22, trace.GoNotExist, trace.GoRunnable, 0),
+		} {
+			if l.running[i] != run {
+				t.Errorf("running[%d] = %d, expected %d", i, l.running[i], run)
+			}
+		}
+		if have, want := l.chain(resume), []int{wake}; !slices.Equal(have, want) {
+			t.Errorf("chain(%d) = %d, expected %d", resume, have, want)
+		}
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		// Goroutine 1 wakes goroutine 21 after goroutine 20 wakes goroutine
+		// 1, and goroutine 1 created goroutine 20. Goroutine 1 was running
+		// when the trace began, so the chain ends there.
+		exit := findTransition(t, evs, 21, trace.GoRunning, trace.GoNotExist, 0)
+		want := []string{
+			"G1: G21 Waiting->Runnable",
+			"G20: G1 Waiting->Runnable",
+			"G1: G20 NotExist->Runnable",
+		}
+		if have := chain(exit); !slices.Equal(have, want) {
+			t.Errorf("chain of goroutine 21's exit = %q, expected %q", have, want)
+		}
+	})
+}
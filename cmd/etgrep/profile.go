@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/google/pprof/profile"
+	"github.com/rhysh/go-tracing-toolbox/internal/profile2"
+	"golang.org/x/exp/trace"
+)
+
+// blockedUntil finds when each goroutine that stops running starts running
+// again. For each StateTransition event that takes a goroutine from Running to
+// Waiting, Runnable, or Syscall, it holds the time of the goroutine's next
+// transition to Running, or the end of the trace if there isn't one. A
+// goroutine that exits doesn't run again, so its exit isn't included.
+func blockedUntil(evs []trace.Event) map[int]trace.Time {
+	until := make(map[int]trace.Time)
+	if len(evs) == 0 {
+		return until
+	}
+	end := evs[len(evs)-1].Time()
+	nextRun := make(map[trace.GoID]trace.Time)
+	for i := len(evs) - 1; i >= 0; i-- {
+		ev := evs[i]
+		if ev.Kind() != trace.EventStateTransition {
+			continue
+		}
+		st := ev.StateTransition()
+		if st.Resource.Kind != trace.ResourceGoroutine {
+			continue
+		}
+		goid := st.Resource.Goroutine()
+		from, to := st.Goroutine()
+		switch {
+		case to == trace.GoRunning:
+			nextRun[goid] = ev.Time()
+		case from == trace.GoRunning && to != trace.GoNotExist:
+			t, ok := nextRun[goid]
+			if !ok {
+				t = end
+			}
+			until[i] = t
+		}
+	}
+	return until
+}
+
+// writeProfile writes the matching events to a pprof profile, with a sample
+// for each event. The "blocked" value is the time from the event until its
+// goroutine runs again, for events that stop a goroutine from running.
+func (c *config) writeProfile(name string, order []int) error {
+	var b profile2.Builder
+	b.Profile.PeriodType = &profile.ValueType{Type: "events", Unit: "count"}
+	b.Profile.Period = 1
+	b.Profile.SampleType = []*profile.ValueType{
+		{Type: "events", Unit: "count"},
+		{Type: "blocked", Unit: "nanoseconds"},
+	}
+	b.Profile.DefaultSampleType = "events"
+
+	until := blockedUntil(c.events)
+	for _, i := range order {
+		if !c.matches(i) {
+			continue
+		}
+		ev := c.events[i]
+		var blocked int64
+		if t, ok := until[i]; ok {
+			blocked = t.Sub(ev.Time()).Nanoseconds()
+		}
+		s := &profile.Sample{
+			Location: b.Stack(ev.Stack()),
+			Value:    []int64{1, blocked},
+			Label: map[string][]string{
+				"event": {ev.Kind().String()},
+			},
+			NumLabel: map[string][]int64{
+				"g": {int64(eventGoroutine(ev))},
+			},
+		}
+		if ev.Kind() == trace.EventStateTransition {
+			st := ev.StateTransition()
+			if st.Resource.Kind == trace.ResourceGoroutine {
+				from, to := st.Goroutine()
+				s.Label["state"] = []string{fmt.Sprintf("%s->%s", from, to)}
+				if st.Reason != "" {
+					s.Label["reason"] = []string{st.Reason}
+				}
+			}
+		}
+		b.Profile.Sample = append(b.Profile.Sample, s)
+	}
+
+	prof := b.Profile.Compact()
+	buf := new(bytes.Buffer)
+	err := prof.Write(buf)
+	if err != nil {
+		return fmt.Errorf("format profile: %w", err)
+	}
+	return os.WriteFile(name, buf.Bytes(), 0644)
+}
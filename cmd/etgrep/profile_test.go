@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/exp/trace"
+)
+
+func TestBlockedUntil(t *testing.T) {
+	evs := loadEvents(t)
+	until := blockedUntil(evs)
+
+	for _, tt := range []struct {
+		name        string
+		stop, start int
+	}{{
+		// Goroutine 1 sleeps until a timer wakes it.
+		name:  "sleep",
+		stop:  findTransition(t, evs, 1, trace.GoRunning, trace.GoWaiting, 1),
+		start: findTransition(t, evs, 1, trace.GoRunnable, trace.GoRunning, 1),
+	}, {
+		name:  "preemption",
+		stop:  findTransition(t, evs, 22, trace.GoRunning, trace.GoRunnable, 0),
+		start: findTransition(t, evs, 22, trace.GoRunnable, trace.GoRunning, 1),
+	}, {
+		// Goroutine 1 loses its P during a slow syscall. It's blocked from
+		// the start of the syscall until it runs again after that.
+		name:  "syscall",
+		stop:  findTransition(t, evs, 1, trace.GoRunning, trace.GoSyscall, 3),
+		start: findTransition(t, evs, 1, trace.GoRunnable, trace.GoRunning, 2),
+	}} {
+		have, ok := until[tt.stop]
+		if !ok {
+			t.Errorf("%s: no time for event %d", tt.name, tt.stop)
+			continue
+		}
+		if want := evs[tt.start].Time(); have != want {
+			t.Errorf("%s: blocked until %d, expected %d", tt.name, have, want)
+		}
+	}
+
+	// Goroutines that exit don't run again, so they aren't blocked.
+	for _, goid := range []trace.GoID{7, 20, 21, 22} {
+		exit := findTransition(t, evs, goid, trace.GoRunning, trace.GoNotExist, 0)
+		if have, ok := until[exit]; ok {
+			t.Errorf("exit of goroutine %d blocked until %d, expected none", goid, have)
+		}
+	}
+
+	// Only events that stop a goroutine from running are included.
+	for i := range until {
+		if ev := evs[i]; ev.Kind() != trace.EventStateTransition {
+			t.Errorf("blockedUntil includes %s event %d", ev.Kind(), i)
+		} else if from, _ := ev.StateTransition().Goroutine(); from != trace.GoRunning {
+			t.Errorf("blockedUntil includes event %d from %s", i, from)
+		}
+	}
+}
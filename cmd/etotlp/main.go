@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/rhysh/go-tracing-toolbox/internal"
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+	"github.com/rhysh/go-tracing-toolbox/internal/otlp"
+)
+
+func main() {
+	input := flag.String("input", "", "Path to JSON lines (from regiongraph -json -summarize, subject to change)")
+	output := flag.String("output", "", "Path to OTLP/JSON output file")
+	endpoint := flag.String("endpoint", "", "OTLP/HTTP endpoint to POST the spans to, such as http://localhost:4318/v1/traces")
+	traceFile := flag.String("trace", "", "Path to the execution trace, to convert its timestamps to wall-clock time (Go 1.25+)")
+	service := flag.String("service", "go-tracing-toolbox", "Value for the service.name resource attribute")
+	flag.Parse()
+
+	if *output == "" && *endpoint == "" {
+		log.Fatalf("Need -output or -endpoint")
+	}
+
+	inFile, err := os.Open(*input)
+	if err != nil {
+		log.Fatalf("Open: %v", err)
+	}
+	defer inFile.Close()
+
+	dec := json.NewDecoder(inFile)
+	var summaries []*cluster.TreeSummary
+	for {
+		var summary cluster.TreeSummary
+		err := dec.Decode(&summary)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("json.Unmarshal: %v", err)
+		}
+		summaries = append(summaries, &summary)
+	}
+
+	var offset int64
+	if *traceFile != "" {
+		var ok bool
+		offset, ok, err = func(name string) (int64, bool, error) {
+			f, err := os.Open(name)
+			if err != nil {
+				return 0, false, err
+			}
+			defer f.Close()
+			return internal.ReadClockOffset(bufio.NewReader(f))
+		}(*traceFile)
+		if err != nil {
+			log.Fatalf("ReadClockOffset(%q); err = %v", *traceFile, err)
+		}
+		if !ok {
+			log.Printf("Execution trace %q has no wall-clock snapshot; using its own timestamps", *traceFile)
+		}
+	}
+
+	td := otlp.Convert(summaries, *service, offset)
+
+	if *output != "" {
+		buf, err := json.Marshal(td)
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		err = os.WriteFile(*output, buf, 0644)
+		if err != nil {
+			log.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	if *endpoint != "" {
+		err := otlp.Post(context.Background(), *endpoint, td)
+		if err != nil {
+			log.Fatalf("Post: %v", err)
+		}
+	}
+}
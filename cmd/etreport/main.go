@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+	"github.com/rhysh/go-tracing-toolbox/internal/report"
+)
+
+func main() {
+	top := flag.Int("top", 10, "Number of slowest examples to list for each kind of root region")
+	flag.Usage = func() {
+		log.Printf("Usage: %s [flags] [file ...]", os.Args[0])
+		log.Printf("Reads JSON lines from regiongraph -json -summarize, from the named files or from stdin.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	r := report.New(*top)
+
+	read := func(name string, rd io.Reader) {
+		dec := json.NewDecoder(rd)
+		for {
+			var summary cluster.TreeSummary
+			err := dec.Decode(&summary)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatalf("json.Unmarshal(%q): %v", name, err)
+			}
+			if summary.Root == nil {
+				continue
+			}
+			r.Add(&summary)
+		}
+	}
+
+	if flag.NArg() == 0 {
+		read("<stdin>", os.Stdin)
+	}
+	for _, name := range flag.Args() {
+		func() {
+			f, err := os.Open(name)
+			if err != nil {
+				log.Fatalf("Open: %v", err)
+			}
+			defer f.Close()
+			read(name, f)
+		}()
+	}
+
+	err := r.WriteText(os.Stdout)
+	if err != nil {
+		log.Fatalf("WriteText: %v", err)
+	}
+}
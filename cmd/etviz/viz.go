@@ -13,8 +13,9 @@ import (
 
 func main() {
 	input := flag.String("input", "", "Path to JSON lines (from regiongraph, subject to change)")
-	output := flag.String("output", "", "Path to SVG output file")
+	output := flag.String("output", "", "Path to SVG (or Chrome trace JSON) output file")
 	details := flag.Bool("details", false, "Show details of the goroutines involved in each cluster")
+	chrome := flag.Bool("chrome", false, "Write Chrome trace event JSON (for ui.perfetto.dev) rather than SVG")
 	flag.Parse()
 
 	inFile, err := os.Open(*input)
@@ -66,7 +67,19 @@ func main() {
 		}
 	}()
 
-	buf := viz.Render(summaries, *details)
+	var buf []byte
+	if *chrome {
+		var roots []*cluster.Span
+		for _, summary := range summaries {
+			roots = append(roots, summary.Root)
+		}
+		buf, err = viz.Chrome(roots)
+		if err != nil {
+			log.Fatalf("viz.Chrome: %v", err)
+		}
+	} else {
+		buf = viz.Render(summaries, *details)
+	}
 	_, err = outFile.Write(buf)
 	if err != nil {
 		log.Fatalf("Write: %v", err)
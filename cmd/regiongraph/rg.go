@@ -10,9 +10,9 @@ import (
 	"strings"
 
 	"github.com/rhysh/go-tracing-toolbox/internal"
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
 	"github.com/rhysh/go-tracing-toolbox/internal/pattern"
+	"github.com/rhysh/go-tracing-toolbox/internal/viz"
 )
 
 func main() {
@@ -22,20 +22,34 @@ func main() {
 	showRegions := flag.Bool("show-regions", false, "Print regions")
 	showJSON := flag.Bool("json", false, "Print clusters in JSON format (subject to change)")
 	summarize := flag.Bool("summarize", false, "Use a summary in the JSON format")
+	showChrome := flag.Bool("chrome", false, "Print clusters in Chrome trace event JSON format, for ui.perfetto.dev")
+	patterns := flag.String("patterns", "", "Path to file of region patterns (default is the built-in patterns)")
 	flag.Parse()
 
+	specs := pattern.DefaultSpecs()
+	if *patterns != "" {
+		var err error
+		specs, err = func(name string) ([]*pattern.Spec, error) {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return pattern.ParseSpecs(f)
+		}(*patterns)
+		if err != nil {
+			log.Fatalf("ParseSpecs(%q); err = %v", *patterns, err)
+		}
+	}
+	track := pattern.TrackSpecs(specs)
+
 	data, err := func(name string) (*internal.Data, error) {
 		f, err := os.Open(name)
 		if err != nil {
 			return nil, err
 		}
 		defer f.Close()
-		result, err := trace.Parse(bufio.NewReader(f), "")
-		if err != nil {
-			return nil, err
-		}
-		data := internal.PrepareData(result.Events)
-		return data, nil
+		return internal.ReadTraceData(bufio.NewReader(f))
 	}(*input)
 	if err != nil {
 		log.Fatalf("Parse; err = %v", err)
@@ -46,7 +60,7 @@ func main() {
 		var fn string
 		for _, ev := range data.GoroutineEvents[g] {
 			if l := len(ev.Stk); l >= 1 {
-				fn = ev.Stk[l-1].Fn
+				fn = ev.Stk[l-1].Function
 				if l >= 2 {
 					// Sometimes the one-frame starting stack shows up as a
 					// wrapper function. Wait for a better one if it's
@@ -60,13 +74,13 @@ func main() {
 
 	var regions []*internal.Region
 	for _, g := range data.GoroutineList {
-		regions = append(regions, pattern.TrackAll(data.GoroutineEvents[g])...)
+		regions = append(regions, track(data.GoroutineEvents[g])...)
 	}
 
 	var rc internal.RegionConnector
 	out := rc.Process(&internal.ProcessRegionConnectionsInput{Data: data, Regions: regions})
 
-	firstSaw := make(map[*internal.RegionStack]*trace.Event)
+	firstSaw := make(map[*internal.RegionStack]*internal.Event)
 	for _, ev := range data.Events {
 		stack := out.EventRegionStacks[ev]
 		if stack == nil {
@@ -131,13 +145,26 @@ func main() {
 		return
 	}
 
+	if *showChrome {
+		spans := cluster.ExtractSpans(data, track)
+		buf, err := viz.Chrome(spans)
+		if err != nil {
+			log.Fatalf("viz.Chrome: %v", err)
+		}
+		fmt.Printf("%s\n", buf)
+		return
+	}
+
 	if *showJSON {
-		spans := cluster.ExtractSpans(data, pattern.TrackAll)
+		spans := cluster.ExtractSpans(data, track)
 
 		for _, span := range spans {
 			var v interface{} = span
 			if *summarize {
-				v = cluster.Summarize(span)
+				summary := cluster.Summarize(span)
+				summary.Trace = *input
+				summary.TraceStartNs = data.StartNs
+				v = summary
 			}
 
 			buf, err := json.Marshal(v)
@@ -13,7 +13,7 @@ import (
 
 	"github.com/google/pprof/profile"
 	"github.com/rhysh/go-tracing-toolbox/internal"
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
+	"github.com/rhysh/go-tracing-toolbox/internal/match2"
 )
 
 func main() {
@@ -29,15 +29,15 @@ func main() {
 			return nil, err
 		}
 		defer f.Close()
-		result, err := trace.Parse(bufio.NewReader(f), "")
+		evs, err := internal.ReadTrace(bufio.NewReader(f))
 		if err != nil {
 			return nil, err
 		}
-		data := internal.PrepareData(result.Events)
+		data := internal.PrepareData(evs)
 		return data, nil
 	}(*input)
 	if err != nil {
-		log.Fatalf("trace.Parse; err = %v", err)
+		log.Fatalf("internal.ReadTrace; err = %v", err)
 	}
 
 	outProfile := new(profile.Profile)
@@ -76,26 +76,26 @@ func main() {
 		// nearly-identical twins (which might have different labels)
 	}
 
-	locFromPC := make(map[uint64]*profile.Location)
+	locFromPC := make(map[uintptr]*profile.Location)
 	fnFromName := make(map[string]*profile.Function)
 
 	for _, g := range data.GoroutineList {
 		gevs := data.GoroutineEvents[g]
 		var (
-			left  *trace.Event
-			right *trace.Event
+			left  *internal.Event
+			right *internal.Event
 		)
 
 		for _, ev := range gevs {
-			if ev.Type == trace.EvGoBlockNet && internal.HasStackRe(ev.Stk,
+			if ev.Type == internal.EvGoBlockNet && match2.HasStackRe(ev.Stk,
 				"**", "^crypto/tls...Conn..readHandshake$",
 				"**", "^net...conn..Read$",
 				"**") {
 				left = ev
 			}
 			if right == nil &&
-				(ev.Type == trace.EvGoSysCall || ev.Type == trace.EvGoBlockNet) &&
-				internal.HasStackRe(ev.Stk,
+				(ev.Type == internal.EvGoSysCall || ev.Type == internal.EvGoBlockNet) &&
+				match2.HasStackRe(ev.Stk,
 					"**", "^crypto/tls...clientHandshakeState..readFinished$",
 					"**", "^net...conn..Read$",
 					"**") {
@@ -106,11 +106,11 @@ func main() {
 			left = left.Link
 			for _, ev := range gevs {
 				if ev.Ts >= left.Ts && ev.Ts < right.Ts {
-					if ev.Type == trace.EvCPUSample {
+					if ev.Type == internal.EvCPUSample {
 						frac := float64(ev.Ts-left.Ts) / float64(right.Ts-left.Ts)
 						var stack []string
 						for _, frame := range ev.Stk {
-							stack = append(stack, frame.Fn)
+							stack = append(stack, frame.Function)
 						}
 						for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
 							stack[i], stack[j] = stack[j], stack[i]
@@ -136,14 +136,14 @@ func main() {
 						for i := len(ev.Stk) - 1; i >= 0; i-- {
 							frame := ev.Stk[i]
 
-							fn, ok := fnFromName[frame.Fn]
+							fn, ok := fnFromName[frame.Function]
 							if !ok {
 								fn = new(profile.Function)
 								fn.ID = uint64(len(outProfile.Function) + 1)
-								fn.Name = frame.Fn
+								fn.Name = frame.Function
 								fn.Filename = frame.File
 
-								fnFromName[frame.Fn] = fn
+								fnFromName[frame.Function] = fn
 								outProfile.Function = append(outProfile.Function, fn)
 							}
 
@@ -151,7 +151,7 @@ func main() {
 							if !ok {
 								loc = new(profile.Location)
 								loc.ID = uint64(len(outProfile.Location) + 1)
-								loc.Address = frame.PC
+								loc.Address = uint64(frame.PC)
 								loc.Line = append(loc.Line, profile.Line{Function: fn, Line: int64(frame.Line)})
 
 								locFromPC[frame.PC] = loc
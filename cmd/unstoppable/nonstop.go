@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/google/pprof/profile"
+	"github.com/rhysh/go-tracing-toolbox/internal/profile2"
 	"golang.org/x/exp/trace"
 )
 
@@ -41,15 +42,15 @@ func main() {
 		log.Fatalf("trace.NewReader: %v", err)
 	}
 
-	var pb profileBuilder
-	pb.profile.PeriodType = &profile.ValueType{Type: "cpu", Unit: "nanoseconds"}
-	pb.profile.Period = 1
+	var pb profile2.Builder
+	pb.Profile.PeriodType = &profile.ValueType{Type: "cpu", Unit: "nanoseconds"}
+	pb.Profile.Period = 1
 
-	pb.profile.SampleType = []*profile.ValueType{
+	pb.Profile.SampleType = []*profile.ValueType{
 		{Type: "samples", Unit: "count"},
 		{Type: "offset", Unit: "nanoseconds"},
 	}
-	pb.profile.DefaultSampleType = "samples"
+	pb.Profile.DefaultSampleType = "samples"
 
 	var (
 		procActive = make(map[trace.ProcID]trace.ProcState)
@@ -173,9 +174,9 @@ func main() {
 							if *printStacks {
 								fmt.Printf("  %s %s:%d %#x\n", f.Func, f.File, f.Line, f.PC)
 							}
-							s.Location = append(s.Location, pb.getLocation(f))
+							s.Location = append(s.Location, pb.Location(f))
 						}
-						pb.profile.Sample = append(pb.profile.Sample, s)
+						pb.Profile.Sample = append(pb.Profile.Sample, s)
 					}
 				}
 
@@ -196,7 +197,7 @@ func main() {
 	}
 
 	if *profFile != "" {
-		prof := pb.profile.Compact()
+		prof := pb.Profile.Compact()
 		prof.SetLabel("trace-sha256", []string{fmt.Sprintf("%02x", hash.Sum(nil))})
 		buf := new(bytes.Buffer)
 		err = prof.Write(buf)
@@ -235,60 +236,3 @@ func (hr *hashReader) Read(p []byte) (int, error) {
 
 	return nr, hashErr
 }
-
-type profileBuilder struct {
-	profile   profile.Profile
-	mapping   profile.Mapping
-	functions map[[2]string]*profile.Function
-	locations map[uint64]*profile.Location
-}
-
-func (pb *profileBuilder) init() {
-	if pb.functions != nil {
-		return
-	}
-	pb.mapping.ID = 1
-	pb.mapping.HasFilenames = true
-	pb.mapping.HasFunctions = true
-	pb.mapping.HasLineNumbers = true
-	pb.profile.Mapping = append(pb.profile.Mapping, &pb.mapping)
-	pb.functions = make(map[[2]string]*profile.Function)
-	pb.locations = make(map[uint64]*profile.Location)
-
-}
-
-func (pb *profileBuilder) getFunction(f trace.StackFrame) *profile.Function {
-	pb.init()
-	key := [2]string{f.Func, f.File}
-	fn, ok := pb.functions[key]
-	if !ok {
-		fn = &profile.Function{
-			Name:       f.Func,
-			SystemName: f.Func,
-			Filename:   f.File,
-			ID:         uint64(len(pb.functions) + 1),
-		}
-		pb.functions[key] = fn
-		pb.profile.Function = append(pb.profile.Function, fn)
-	}
-	return fn
-}
-
-func (pb *profileBuilder) getLocation(f trace.StackFrame) *profile.Location {
-	pb.init()
-	l, ok := pb.locations[f.PC]
-	if !ok {
-		l = &profile.Location{
-			Address: f.PC,
-			Line: []profile.Line{{
-				Function: pb.getFunction(f),
-				Line:     int64(f.Line),
-			}},
-			Mapping: &pb.mapping,
-			ID:      uint64(len(pb.locations) + 1),
-		}
-		pb.locations[f.PC] = l
-		pb.profile.Location = append(pb.profile.Location, l)
-	}
-	return l
-}
@@ -1,9 +0,0 @@
-//go:build ignore
-
-// Try:
-//
-//  $ go generate -x ./cp.go
-
-package _vendor
-
-//go:generate ./cp.sh
@@ -0,0 +1,120 @@
+package cluster
+
+import (
+	"sort"
+
+	"github.com/rhysh/go-tracing-toolbox/internal"
+)
+
+// A Segment is a stretch of time on a critical path, during which a single
+// goroutine's progress determined when the work would finish.
+type Segment struct {
+	// G is the id of the goroutine on the critical path.
+	G uint64
+	// StartNs is the start time of this Segment measured in nanoseconds.
+	StartNs int64
+	// LengthNs is the duration of this Segment measured in nanoseconds.
+	LengthNs int64
+	// Reason is "running" when the goroutine was on-CPU. Otherwise, it's the
+	// reason the goroutine was waiting, as in Span.StartWait: "cpu" when it
+	// was waiting to be scheduled, "net" when it was waiting for the network,
+	// and so on.
+	Reason string
+	// Stack lists the function names of the goroutine's call stack, leaf
+	// first. For a wait, that's where the goroutine stopped running; for
+	// running, that's where the next event found it.
+	Stack []string `json:",omitempty"`
+}
+
+// CriticalPath finds the chain of work that determined when a Span finished.
+//
+// It starts from the Span's final event and walks backwards through time. When
+// the goroutine was running, or waiting on something outside of the program
+// (such as the network or a timer), that time is on the critical path. When
+// another goroutine made it runnable, the path continues on that goroutine
+// from the moment it did so. The walk stops when it reaches the Span's start.
+//
+// The Segments are in chronological order, and cover the Span's duration
+// except where the trace is missing the events to explain it.
+func CriticalPath(data *internal.Data, span *Span) []*Segment {
+	start, end := span.StartNs, span.StartNs+span.LengthNs
+
+	evs := data.GoroutineEvents[span.G]
+	i := sort.Search(len(evs), func(i int) bool { return evs[i].Ts > end }) - 1
+	if i < 0 || evs[i].Ts < start {
+		return nil
+	}
+
+	var path []*Segment
+	add := func(g uint64, from, to int64, reason string, stk *internal.Event) {
+		from, to = max(from, start), min(to, end)
+		if from >= to {
+			return
+		}
+		seg := &Segment{G: g, StartNs: from, LengthNs: to - from, Reason: reason}
+		if stk != nil {
+			for _, frame := range stk.Stk {
+				seg.Stack = append(seg.Stack, frame.Function)
+			}
+		}
+		path = append(path, seg)
+	}
+
+	for cur := evs[i]; cur != nil && cur.Ts > start; {
+		if cur.Type == internal.EvGoStart {
+			if from := data.Backlinks[cur]; from != nil && from.G != cur.G {
+				add(cur.G, from.Ts, cur.Ts, "cpu", nil)
+				if from.G != 0 {
+					// Another goroutine made this one runnable. What it was
+					// doing before then is what we were waiting for.
+					cur = from
+					continue
+				}
+				// The network poller or a timer made this goroutine
+				// runnable. We were waiting for whatever made it block.
+				prev := data.Prev[cur]
+				if prev == nil {
+					break
+				}
+				add(cur.G, prev.Ts, from.Ts, blockReason(prev), prev)
+				cur = prev
+				continue
+			}
+		}
+
+		prev := data.Prev[cur]
+		if prev == nil {
+			break
+		}
+		if reason := blockReason(prev); reason != "" {
+			add(cur.G, prev.Ts, cur.Ts, reason, prev)
+		} else if len(cur.Stk) > 0 {
+			add(cur.G, prev.Ts, cur.Ts, "running", cur)
+		} else {
+			add(cur.G, prev.Ts, cur.Ts, "running", prev)
+		}
+		cur = prev
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// blockReason returns the reason a goroutine isn't running after the event,
+// or "" if it's still running.
+func blockReason(ev *internal.Event) string {
+	if reason, ok := waitReasons[ev.Type]; ok {
+		return stackWaitReason(ev, reason)
+	}
+	switch ev.Type {
+	case internal.EvGoSched:
+		return "cpu"
+	case internal.EvGoInSyscall:
+		return "syscall"
+	case internal.EvGoWaiting:
+		return "other"
+	}
+	return ""
+}
@@ -5,7 +5,7 @@ import (
 	"sort"
 
 	"github.com/rhysh/go-tracing-toolbox/internal"
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
+	"github.com/rhysh/go-tracing-toolbox/internal/match2"
 )
 
 // A Span describes a single goroutine's contribution of a unit of useful work.
@@ -37,6 +37,10 @@ type Span struct {
 
 	// Caused lists Spans that this Span caused to exist.
 	Caused []*Span
+
+	// CriticalPath describes the chain of work that determined when this
+	// Span finished. ExtractSpans sets it on the root of each tree of Spans.
+	CriticalPath []*Segment `json:",omitempty"`
 }
 
 // A TreeSummary describes the overall behavior of a tree of Spans.
@@ -67,13 +71,22 @@ type TreeSummary struct {
 	//  - "gc" is second, again because it's getting in the way of what would be on-CPU time.
 	//  - "net", as it's likely to represent forces outside of the process.
 	//  - "syscall", again because it's likely to show us interesting cross-process waiting.
-	//  - Finally, process-internal synchronization: "select", "recv", "send", "cond", "sync", "block".
+	//  - "dbpool", waiting for a connection from a database/sql.DB's pool.
+	//  - Then process-internal synchronization: "select", "recv", "send", "cond", "sync", "block".
+	//  - Finally, "sleep", since the goroutine asked to be idle.
 	FlatRunNs    int64
 	FlatAssistNs map[string]int64
 	FlatWaitNs   map[string]int64
 
 	Flat *Span
 	Root *Span
+
+	// Trace is the path to the execution trace file that these Spans came
+	// from, when known.
+	Trace string `json:",omitempty"`
+	// TraceStartNs is the timestamp of the first event in that execution
+	// trace. Tools like "go tool trace" show times relative to it.
+	TraceStartNs int64 `json:",omitempty"`
 }
 
 type Ranges struct {
@@ -199,7 +212,9 @@ func Summarize(root *Span) *TreeSummary {
 		"gc",
 		"net",
 		"syscall",
+		"dbpool",
 		"select", "recv", "send", "cond", "sync", "block",
+		"sleep",
 		"other",
 	}
 
@@ -511,13 +526,44 @@ func Visit(span *Span, fn func(span *Span)) {
 	}
 }
 
-func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*internal.Region) []*Span {
+// waitReasons maps the events where a goroutine stops running to the reason
+// for the wait that follows, as used in Span.StartWait.
+var waitReasons = map[internal.EventType]string{
+	internal.EvGoPreempt:     "cpu",
+	internal.EvGoBlock:       "block",
+	internal.EvGoBlockCond:   "cond",
+	internal.EvGoBlockGC:     "gc",
+	internal.EvGoBlockNet:    "net",
+	internal.EvGoBlockRecv:   "recv",
+	internal.EvGoBlockSelect: "select",
+	internal.EvGoBlockSend:   "send",
+	internal.EvGoBlockSync:   "sync",
+	internal.EvGoSleep:       "sleep",
+	internal.EvGoSysBlock:    "syscall",
+}
+
+// stackWaitReason returns a more specific reason for a goroutine to wait on
+// another, based on the call stack where it started waiting. Waits for the
+// network and other outside forces keep their reason.
+func stackWaitReason(ev *internal.Event, reason string) string {
+	switch reason {
+	case "select", "recv", "send", "cond", "sync", "block":
+	default:
+		return reason
+	}
+	if match2.HasStackRe(ev.Stk, "**", `^database/sql...DB..conn$`, "**") {
+		return "dbpool"
+	}
+	return reason
+}
+
+func ExtractSpans(data *internal.Data, findRegions func([]*internal.Event) []*internal.Region) []*Span {
 	rootFunc := make(map[uint64]string)
 	for _, g := range data.GoroutineList {
 		var fn string
 		for _, ev := range data.GoroutineEvents[g] {
 			if l := len(ev.Stk); l >= 1 {
-				fn = ev.Stk[l-1].Fn
+				fn = ev.Stk[l-1].Function
 				if l >= 2 {
 					// Sometimes the one-frame starting stack shows up as a
 					// wrapper function. Wait for a better one if it's
@@ -537,7 +583,7 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 	var rc internal.RegionConnector
 	out := rc.Process(&internal.ProcessRegionConnectionsInput{Data: data, Regions: regions})
 
-	firstSaw := make(map[*internal.RegionStack]*trace.Event)
+	firstSaw := make(map[*internal.RegionStack]*internal.Event)
 	for _, ev := range data.Events {
 		stack := out.EventRegionStacks[ev]
 		if stack == nil {
@@ -574,6 +620,17 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 			regionsFromRoot[root] = append(regionsFromRoot[root], child)
 		}
 	}
+	for stack := range firstSaw {
+		// A Root region starts a unit of work, such as an inbound request.
+		// Report it even when it didn't cause any other work. Shared Root
+		// regions are bookkeeping for the whole connection, and are only
+		// interesting when they lead somewhere.
+		if l := stack.Local; stack.Parent == nil && l != nil && l.Root() && !l.Shared() {
+			if _, ok := regionsFromRoot[stack]; !ok {
+				regionsFromRoot[stack] = nil
+			}
+		}
+	}
 	treeFromStack := make(map[*internal.RegionStack]*tree)
 	for root, children := range regionsFromRoot {
 		treeFromStack[root] = &tree{self: root}
@@ -646,7 +703,7 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 				parent.Caused = append(parent.Caused, span)
 			}
 
-			var evs []*trace.Event
+			var evs []*internal.Event
 			for ev := stack.Start; ev != nil; ev = data.Next[ev] {
 				evStack := out.EventRegionStacks[ev]
 				var ok bool
@@ -663,31 +720,19 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 				}
 			}
 
-			startEvents := map[byte]struct{}{
-				trace.EvGoStart:          {},
-				trace.EvGCMarkAssistDone: {},
-			}
-			assistEvents := map[byte]string{
-				trace.EvGCMarkAssistStart: "gc",
+			startEvents := map[internal.EventType]struct{}{
+				internal.EvGoStart:          {},
+				internal.EvGCMarkAssistDone: {},
 			}
-			waitEvents := map[byte]string{
-				trace.EvGoPreempt:     "cpu",
-				trace.EvGoBlock:       "block",
-				trace.EvGoBlockCond:   "cond",
-				trace.EvGoBlockGC:     "gc",
-				trace.EvGoBlockNet:    "net",
-				trace.EvGoBlockRecv:   "recv",
-				trace.EvGoBlockSelect: "select",
-				trace.EvGoBlockSend:   "send",
-				trace.EvGoBlockSync:   "sync",
-				trace.EvGoSysBlock:    "syscall",
+			assistEvents := map[internal.EventType]string{
+				internal.EvGCMarkAssistStart: "gc",
 			}
 
-			changeEv := make([]*trace.Event, 0, len(evs))
+			changeEv := make([]*internal.Event, 0, len(evs))
 			for _, ev := range evs {
 				_, ok1 := startEvents[ev.Type]
 				_, ok2 := assistEvents[ev.Type]
-				_, ok3 := waitEvents[ev.Type]
+				_, ok3 := waitReasons[ev.Type]
 				if ok1 || ok2 || ok3 || len(changeEv) == 0 {
 					changeEv = append(changeEv, ev)
 				}
@@ -695,7 +740,7 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 
 			for i := 0; i < len(changeEv); i++ {
 				ev := changeEv[i]
-				var next *trace.Event
+				var next *internal.Event
 				if i < len(changeEv)-1 {
 					next = changeEv[i+1]
 				}
@@ -703,7 +748,7 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 				// took to schedule that goroutine means delay for the root
 				// Span. Count that as "cpu" wait time before the Span's zero
 				// time.
-				if i == 0 && ev.Type == trace.EvGoStart {
+				if i == 0 && ev.Type == internal.EvGoStart {
 					if prev := data.Backlinks[ev]; prev != nil {
 						wait := span.StartNs - prev.Ts
 						if wait > 0 {
@@ -716,7 +761,8 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 					span.StartAssist[reason] = append(span.StartAssist[reason], ev.Ts-span.StartNs)
 					continue
 				}
-				if reason, ok := waitEvents[ev.Type]; ok {
+				if reason, ok := waitReasons[ev.Type]; ok {
+					reason = stackWaitReason(ev, reason)
 					span.StartWait[reason] = append(span.StartWait[reason], ev.Ts-span.StartNs)
 					if ev.Link != nil && next != nil && ev.Ts < ev.Link.Ts && ev.Link.Ts < next.Ts {
 						span.StartWait["cpu"] = append(span.StartWait["cpu"], ev.Link.Ts-span.StartNs)
@@ -729,6 +775,7 @@ func ExtractSpans(data *internal.Data, findRegions func([]*trace.Event) []*inter
 			span.LengthNs = evs[len(evs)-1].Ts - stack.Start.Ts
 		})
 		rootSpan := spanFromTree[t]
+		rootSpan.CriticalPath = CriticalPath(data, rootSpan)
 		spans = append(spans, rootSpan)
 	}
 
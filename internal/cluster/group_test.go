@@ -1,11 +1,12 @@
 package cluster_test
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/rhysh/go-tracing-toolbox/internal"
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
 	"github.com/rhysh/go-tracing-toolbox/internal/pattern"
 	"github.com/rhysh/go-tracing-toolbox/internal/testhelp"
@@ -90,10 +91,226 @@ func TestMore(t *testing.T) {
 	}
 }
 
+func TestGRPCRoots(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/grpc_proxy")
+
+	spans := cluster.ExtractSpans(data, pattern.TrackAll)
+
+	// Each inbound RPC, to the front server and to the backend, is its own
+	// root Span. The connections' reader and loopyWriter goroutines do
+	// Shared work, and don't join them together.
+	var have []uint64
+	for _, span := range spans {
+		if span.Kind == "server/grpc" {
+			have = append(have, span.G)
+		}
+		cluster.Visit(span, func(child *cluster.Span) {
+			if child != span && child.Kind == "server/grpc" {
+				t.Errorf("server/grpc Span on g%d is within %q Span on g%d", child.G, span.Kind, span.G)
+			}
+		})
+	}
+	if want := []uint64{41, 42, 44, 45, 47, 48, 50, 51, 53, 54}; !reflect.DeepEqual(have, want) {
+		t.Errorf("server/grpc root Spans on goroutines %v, expected %v", have, want)
+	}
+}
+
+func TestHTTP2Roots(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/http2_proxy")
+
+	spans := cluster.ExtractSpans(data, pattern.TrackAll)
+
+	// Each inbound stream, to the front server and to the backend, is its own
+	// root Span. The front server's connection is served by g11, and the
+	// backend's by g24. Those goroutines read frames for every stream, which
+	// doesn't join the streams together, but the frames they write for a
+	// stream's response belong to that stream.
+	serveG := map[uint64]uint64{
+		50: 11, 59: 11, 68: 11, 77: 11, 86: 11,
+		52: 24, 61: 24, 70: 24, 79: 24, 88: 24,
+	}
+	var have []uint64
+	for _, span := range spans {
+		if span.Kind != "server/http2" {
+			continue
+		}
+		have = append(have, span.G)
+
+		var writes, roundTrips int
+		cluster.Visit(span, func(child *cluster.Span) {
+			switch child.Kind {
+			case "server/http2", "server/http2_read":
+				if child != span {
+					t.Errorf("%q Span on g%d is within server/http2 Span on g%d", child.Kind, child.G, span.G)
+				}
+			case "server/http2_write":
+				writes++
+				if want := serveG[span.G]; child.G != want {
+					t.Errorf("server/http2 Span on g%d includes writes from g%d, expected g%d", span.G, child.G, want)
+				}
+			case "client/http2_roundtrip":
+				roundTrips++
+			}
+		})
+		if writes != 1 {
+			t.Errorf("server/http2 Span on g%d includes %d server/http2_write Spans, expected 1", span.G, writes)
+		}
+		// The front server's handlers proxy the request to the backend.
+		wantRoundTrips := 0
+		if serveG[span.G] == 11 {
+			wantRoundTrips = 1
+		}
+		if roundTrips != wantRoundTrips {
+			t.Errorf("server/http2 Span on g%d includes %d client/http2_roundtrip Spans, expected %d", span.G, roundTrips, wantRoundTrips)
+		}
+	}
+	if want := []uint64{50, 52, 59, 61, 68, 70, 77, 79, 86, 88}; !reflect.DeepEqual(have, want) {
+		t.Errorf("server/http2 root Spans on goroutines %v, expected %v", have, want)
+	}
+}
+
+func TestSQLPoolWait(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/sql_pool")
+
+	spans := cluster.ExtractSpans(data, pattern.TrackAll)
+
+	var root *cluster.Span
+	for _, span := range spans {
+		if span.G == 11 && span.StartNs == 1830402183616 && span.Kind == "client/sql_query" {
+			root = span
+			break
+		}
+	}
+	if root == nil {
+		t.Fatalf("Could not find root span")
+	}
+
+	summary := cluster.Summarize(root)
+
+	// The query waits in a select statement for another goroutine to return a
+	// connection to the pool. That counts as "dbpool", not "select".
+	if have, want := summary.FlatWaitNs, map[string]int64{
+		"cpu":    3_200,
+		"dbpool": 7_517_696,
+		"sleep":  1_078_080,
+	}; !reflect.DeepEqual(have, want) {
+		t.Errorf("FlatWaitNs; %v != %v", have, want)
+	}
+}
+
+func TestUserTasks(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/user_task")
+
+	spans := cluster.ExtractSpans(data, pattern.TrackAll)
+
+	// Each "request" task collects the work done for it on other goroutines,
+	// whether the goroutine was woken to do it ("work" on g 7) or found it on
+	// its own ("work" on g 6 for g 13, and each "flush").
+	var have []string
+	for _, span := range spans {
+		var visit func(span *cluster.Span, depth int)
+		visit = func(span *cluster.Span, depth int) {
+			have = append(have, fmt.Sprintf("%s%s g=%d", strings.Repeat("  ", depth), span.Kind, span.G))
+			for _, child := range span.Caused {
+				visit(child, depth+1)
+			}
+		}
+		visit(span, 0)
+	}
+	want := []string{
+		"request g=14",
+		"  parse g=14",
+		"  redacted.mod/pkg.fn g=6",
+		"    work g=6",
+		"  flush g=8",
+		"    write g=8",
+		"request g=12",
+		"  parse g=12",
+		"  redacted.mod/pkg.fn g=7",
+		"    work g=7",
+		"  flush g=8",
+		"    write g=8",
+		"request g=13",
+		"  parse g=13",
+		"  work g=6",
+		"  flush g=8",
+		"    write g=8",
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("spans;\n%s\n!=\n%s", strings.Join(have, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/sql_pool")
+
+	spans := cluster.ExtractSpans(data, pattern.TrackAll)
+
+	var root *cluster.Span
+	for _, span := range spans {
+		if span.G == 11 && span.StartNs == 1830402183616 && span.Kind == "client/sql_query" {
+			root = span
+			break
+		}
+	}
+	if root == nil {
+		t.Fatalf("Could not find root span")
+	}
+
+	// The query waited for g13 to return a connection to the pool, and g13
+	// was waiting for g10 to return one before that.
+	path := root.CriticalPath
+	var goroutines []uint64
+	for i, seg := range path {
+		if i == 0 || seg.G != path[i-1].G {
+			goroutines = append(goroutines, seg.G)
+		}
+		if i > 0 {
+			if prev := path[i-1]; prev.StartNs+prev.LengthNs != seg.StartNs {
+				t.Errorf("segment %d ends at %d, but segment %d starts at %d", i-1, prev.StartNs+prev.LengthNs, i, seg.StartNs)
+			}
+		}
+	}
+	if have, want := goroutines, []uint64{10, 13, 11}; !reflect.DeepEqual(have, want) {
+		t.Errorf("critical path goroutines; %v != %v", have, want)
+	}
+	if len(path) > 0 {
+		if have, want := path[0].StartNs, root.StartNs; have != want {
+			t.Errorf("critical path start; %d != %d", have, want)
+		}
+		last := path[len(path)-1]
+		if have, want := last.StartNs+last.LengthNs, root.StartNs+root.LengthNs; have != want {
+			t.Errorf("critical path end; %d != %d", have, want)
+		}
+	}
+
+	// g13 handed the connection over when it committed its transaction, and
+	// sent on a channel to do so.
+	for i, seg := range path {
+		if seg.G == 11 {
+			prev := path[i-1]
+			if have, want := prev.Reason, "running"; have != want {
+				t.Errorf("reason before handoff; %q != %q", have, want)
+			}
+			if have, want := prev.Stack[0], "runtime.chansend1"; have != want {
+				t.Errorf("leaf function before handoff; %q != %q", have, want)
+			}
+			break
+		}
+	}
+
+	// Only the roots carry a critical path.
+	cluster.Visit(root, func(span *cluster.Span) {
+		if span != root && span.CriticalPath != nil {
+			t.Errorf("non-root %q span on g %d has a critical path", span.Kind, span.G)
+		}
+	})
+}
+
 func TestManualA(t *testing.T) {
 	data := testhelp.Load(t, "../../testdata/manual/a")
 
-	spans := cluster.ExtractSpans(data, func(evs []*trace.Event) []*internal.Region {
+	spans := cluster.ExtractSpans(data, func(evs []*internal.Event) []*internal.Region {
 		return []*internal.Region{{Events: evs, Kind: "goroutine"}}
 	})
 
@@ -2,8 +2,6 @@ package internal
 
 import (
 	"sort"
-
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 )
 
 type ProcessRegionConnectionsInput struct {
@@ -12,22 +10,23 @@ type ProcessRegionConnectionsInput struct {
 }
 
 type ProcessRegionConnectionsResult struct {
-	EventRegionStacks map[*trace.Event]*RegionStack
+	EventRegionStacks map[*Event]*RegionStack
 }
 
 type RegionConnector struct {
 	// StartRegion sets the new RegionStack of a goroutine when it starts a new
 	// local Region. If left unset, the RegionConnector uses a default behavior
-	// of adding the starting RegionStack on top of the existing RegionStack.
-	StartRegion func(ev *trace.Event, existing, starting *RegionStack) *RegionStack
+	// of adding the starting RegionStack on top of the existing RegionStack,
+	// or of replacing it when one of the starting Regions is a Root.
+	StartRegion func(ev *Event, existing, starting *RegionStack) *RegionStack
 	// ApplyOnWake sets the new RegionStack for a goroutine when it is made
 	// runnable by another goroutine's event ev. The GoStart event for the
 	// now-runnable goroutine is ev.Link. If left unset, the RegionConnector
 	// uses a default behavior of trimming the inbound RegionStack back to
-	ApplyOnWake func(ev *trace.Event, existing, inbound *RegionStack) *RegionStack
+	ApplyOnWake func(ev *Event, existing, inbound *RegionStack) *RegionStack
 }
 
-func (rc *RegionConnector) DoStartRegion(ev *trace.Event, existing, starting *RegionStack) *RegionStack {
+func (rc *RegionConnector) DoStartRegion(ev *Event, existing, starting *RegionStack) *RegionStack {
 	if rc != nil && rc.StartRegion != nil {
 		return rc.StartRegion(ev, existing, starting)
 	} else {
@@ -36,6 +35,7 @@ func (rc *RegionConnector) DoStartRegion(ev *trace.Event, existing, starting *Re
 		// regions.
 
 		var head, tail *RegionStack
+		root := false
 		for node := starting; node != nil; node = node.Parent {
 			next := &RegionStack{Start: node.Start, Local: node.Local, Parent: nil}
 			if head == nil {
@@ -45,13 +45,21 @@ func (rc *RegionConnector) DoStartRegion(ev *trace.Event, existing, starting *Re
 				tail.Parent = next
 			}
 			tail = next
+			if node.Local != nil && node.Local.Root() {
+				root = true
+			}
+		}
+		if root {
+			// A Root region starts a new unit of work, unrelated to
+			// whatever the goroutine was doing before.
+			return head
 		}
 		tail.Parent = existing
 		return head
 	}
 }
 
-func (rc *RegionConnector) DoApplyOnWake(ev *trace.Event, existing, inbound *RegionStack) *RegionStack {
+func (rc *RegionConnector) DoApplyOnWake(ev *Event, existing, inbound *RegionStack) *RegionStack {
 	if rc != nil && rc.ApplyOnWake != nil {
 		return rc.ApplyOnWake(ev, existing, inbound)
 	} else {
@@ -86,12 +94,12 @@ func (rc *RegionConnector) DoApplyOnWake(ev *trace.Event, existing, inbound *Reg
 
 func (rc *RegionConnector) Process(in *ProcessRegionConnectionsInput) *ProcessRegionConnectionsResult {
 	out := &ProcessRegionConnectionsResult{
-		EventRegionStacks: make(map[*trace.Event]*RegionStack, len(in.Data.Events)),
+		EventRegionStacks: make(map[*Event]*RegionStack, len(in.Data.Events)),
 	}
 
 	goroutineRegions := make(map[uint64][]*Region)
-	starts := make(map[*trace.Event][]*Region)
-	ends := make(map[*trace.Event][]*Region)
+	starts := make(map[*Event][]*Region)
+	ends := make(map[*Event][]*Region)
 
 	for _, region := range in.Regions {
 		ev0 := region.Events[0]
@@ -122,6 +130,13 @@ func (rc *RegionConnector) Process(in *ProcessRegionConnectionsInput) *ProcessRe
 
 	stackNow := make(map[uint64]*RegionStack)
 
+	// taskStacks holds the RegionStack of each user task's Region, so the
+	// Regions that do work for the task can link to it. When a Region takes
+	// on a task's explanation that way, resume holds the goroutine's prior
+	// RegionStack to restore once the Region ends.
+	taskStacks := make(map[uint64]*RegionStack)
+	resume := make(map[*Region]*RegionStack)
+
 	for _, ev := range in.Data.Events {
 		why := stackNow[ev.G]
 
@@ -132,11 +147,26 @@ func (rc *RegionConnector) Process(in *ProcessRegionConnectionsInput) *ProcessRe
 			fresh = &RegionStack{Start: ev, Local: freshList[i], Parent: fresh}
 		}
 		if fresh != nil {
-			why = rc.DoStartRegion(ev, why, fresh)
+			existing := why
+			for _, region := range freshList {
+				if task := taskStacks[region.ParentTask]; task != nil && !explainsTask(why, region.ParentTask) {
+					// The goroutine's own history doesn't show that it's
+					// working on this task. Believe the annotation.
+					existing = task
+					resume[freshList[len(freshList)-1]] = why
+					break
+				}
+			}
+			why = rc.DoStartRegion(ev, existing, fresh)
+			for link := why; link != nil && link.Start == ev; link = link.Parent {
+				if link.Local != nil && link.Local.Task != 0 {
+					taskStacks[link.Local.Task] = link
+				}
+			}
 		}
 
 		// Apply regions to peers
-		if ev.Link != nil && ev.Link.Type == trace.EvGoStart && ev.G != ev.Link.G {
+		if ev.Link != nil && ev.Link.Type == EvGoStart && ev.G != ev.Link.G {
 			stackNow[ev.Link.G] = rc.DoApplyOnWake(ev, stackNow[ev.Link.G], why)
 		}
 
@@ -154,6 +184,9 @@ func (rc *RegionConnector) Process(in *ProcessRegionConnectionsInput) *ProcessRe
 			}
 			if _, ok := staleSet[link.Local]; ok {
 				why = link.Parent
+				if prior, ok := resume[link.Local]; ok {
+					why = prior
+				}
 				for i := len(activeLocal) - 1; i >= 0; i-- {
 					local := activeLocal[i]
 					why = &RegionStack{Local: local, Parent: why, Start: local.Events[0]}
@@ -167,3 +200,14 @@ func (rc *RegionConnector) Process(in *ProcessRegionConnectionsInput) *ProcessRe
 
 	return out
 }
+
+// explainsTask returns whether the RegionStack includes a Region of the user
+// task, or a Region that does work for it.
+func explainsTask(stack *RegionStack, task uint64) bool {
+	for link := stack; link != nil; link = link.Parent {
+		if l := link.Local; l != nil && (l.Task == task || l.ParentTask == task) {
+			return true
+		}
+	}
+	return false
+}
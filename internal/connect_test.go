@@ -8,7 +8,6 @@ import (
 	"testing"
 
 	"github.com/rhysh/go-tracing-toolbox/internal"
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 	"github.com/rhysh/go-tracing-toolbox/internal/pattern"
 	"github.com/rhysh/go-tracing-toolbox/internal/testhelp"
 )
@@ -16,10 +15,10 @@ import (
 func TestTasks(t *testing.T) {
 	// Here's an opportunity to add logging to or alter the connection rules.
 	rc := &internal.RegionConnector{
-		StartRegion: func(ev *trace.Event, existing, starting *internal.RegionStack) *internal.RegionStack {
+		StartRegion: func(ev *internal.Event, existing, starting *internal.RegionStack) *internal.RegionStack {
 			return (*internal.RegionConnector)(nil).DoStartRegion(ev, existing, starting)
 		},
-		ApplyOnWake: func(ev *trace.Event, existing, inbound *internal.RegionStack) *internal.RegionStack {
+		ApplyOnWake: func(ev *internal.Event, existing, inbound *internal.RegionStack) *internal.RegionStack {
 			return (*internal.RegionConnector)(nil).DoApplyOnWake(ev, existing, inbound)
 		},
 	}
@@ -70,7 +69,7 @@ func TestTasks(t *testing.T) {
 		data := testhelp.Load(t, "../testdata/7de55ef9_go1.15.10/dial_own_tls_with_shared_dns")
 
 		var regions []*internal.Region
-		for _, fn := range []func([]*trace.Event) []*internal.Region{
+		for _, fn := range []func([]*internal.Event) []*internal.Region{
 			pattern.TrackHTTP1Writer,
 			pattern.TrackHTTP1Reader,
 			pattern.TrackHTTPClient,
@@ -232,7 +231,7 @@ func TestTasks(t *testing.T) {
 
 	t.Run("basic tracking", testcase(func(data *internal.Data) []*internal.Region {
 		var regions []*internal.Region
-		for _, fn := range []func([]*trace.Event) []*internal.Region{
+		for _, fn := range []func([]*internal.Event) []*internal.Region{
 			pattern.TrackHTTP1Writer,
 			pattern.TrackHTTP1Reader,
 			pattern.TrackHTTPClient,
@@ -278,7 +277,7 @@ func TestTasks(t *testing.T) {
 
 	t.Run("add dialer", testcase(func(data *internal.Data) []*internal.Region {
 		var regions []*internal.Region
-		for _, fn := range []func([]*trace.Event) []*internal.Region{
+		for _, fn := range []func([]*internal.Event) []*internal.Region{
 			pattern.TrackHTTP1Writer,
 			pattern.TrackHTTP1Reader,
 			pattern.TrackHTTPClient,
@@ -335,7 +334,7 @@ func TestTasks(t *testing.T) {
 
 	t.Run("add dns", testcase(func(data *internal.Data) []*internal.Region {
 		var regions []*internal.Region
-		for _, fn := range []func([]*trace.Event) []*internal.Region{
+		for _, fn := range []func([]*internal.Event) []*internal.Region{
 			pattern.TrackHTTP1Writer,
 			pattern.TrackHTTP1Reader,
 			pattern.TrackHTTPClient,
@@ -380,7 +379,7 @@ func TestTasks(t *testing.T) {
 
 	t.Run("add dialer and dns", testcase(func(data *internal.Data) []*internal.Region {
 		var regions []*internal.Region
-		for _, fn := range []func([]*trace.Event) []*internal.Region{
+		for _, fn := range []func([]*internal.Event) []*internal.Region{
 			pattern.TrackHTTP1Writer,
 			pattern.TrackHTTP1Reader,
 			pattern.TrackHTTPClient,
@@ -445,10 +444,10 @@ func TestTasks(t *testing.T) {
 func TestSimultaneousStart(t *testing.T) {
 	// Here's an opportunity to add logging to or alter the connection rules.
 	rc := &internal.RegionConnector{
-		StartRegion: func(ev *trace.Event, existing, starting *internal.RegionStack) *internal.RegionStack {
+		StartRegion: func(ev *internal.Event, existing, starting *internal.RegionStack) *internal.RegionStack {
 			return (*internal.RegionConnector)(nil).DoStartRegion(ev, existing, starting)
 		},
-		ApplyOnWake: func(ev *trace.Event, existing, inbound *internal.RegionStack) *internal.RegionStack {
+		ApplyOnWake: func(ev *internal.Event, existing, inbound *internal.RegionStack) *internal.RegionStack {
 			return (*internal.RegionConnector)(nil).DoApplyOnWake(ev, existing, inbound)
 		},
 	}
@@ -466,7 +465,7 @@ func TestSimultaneousStart(t *testing.T) {
 		},
 	}
 
-	resulting := rc.DoStartRegion(new(trace.Event), existing, starting)
+	resulting := rc.DoStartRegion(new(internal.Event), existing, starting)
 
 	var kinds []string
 	for node := resulting; node != nil; node = node.Parent {
@@ -3,13 +3,11 @@ package internal
 import (
 	"sort"
 	"time"
-
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 )
 
 type TimeSpan [2]time.Duration
 
-func newTimeSpan(ev1, ev2 *trace.Event) TimeSpan {
+func newTimeSpan(ev1, ev2 *Event) TimeSpan {
 	return TimeSpan{time.Duration(ev1.Ts), time.Duration(ev2.Ts)}
 }
 
@@ -17,7 +15,7 @@ func (ts TimeSpan) Start() time.Duration  { return ts[0] }
 func (ts TimeSpan) End() time.Duration    { return ts[1] }
 func (ts TimeSpan) Length() time.Duration { return ts[1] - ts[0] }
 
-type EventList []*trace.Event
+type EventList []*Event
 
 func (list EventList) Extent() TimeSpan {
 	return newTimeSpan(list[0], list[len(list)-1])
@@ -25,7 +23,7 @@ func (list EventList) Extent() TimeSpan {
 
 func (list EventList) Running() []TimeSpan {
 	var out []TimeSpan
-	var start *trace.Event
+	var start *Event
 	for _, ev := range list {
 		if start == nil {
 			start = ev
@@ -34,15 +32,16 @@ func (list EventList) Running() []TimeSpan {
 		switch ev.Type {
 		default:
 			blocked = false
-		case trace.EvGoBlock:
-		case trace.EvGoBlockSend:
-		case trace.EvGoBlockRecv:
-		case trace.EvGoBlockSelect:
-		case trace.EvGoBlockSync:
-		case trace.EvGoBlockCond:
-		case trace.EvGoBlockNet:
-		case trace.EvGoSysBlock:
-		case trace.EvGoBlockGC:
+		case EvGoBlock:
+		case EvGoBlockSend:
+		case EvGoBlockRecv:
+		case EvGoBlockSelect:
+		case EvGoBlockSync:
+		case EvGoBlockCond:
+		case EvGoBlockNet:
+		case EvGoSysBlock:
+		case EvGoSleep:
+		case EvGoBlockGC:
 		}
 		if blocked {
 			out = append(out, newTimeSpan(start, ev))
@@ -54,14 +53,14 @@ func (list EventList) Running() []TimeSpan {
 
 func (list EventList) BlockNet() []TimeSpan {
 	var out []TimeSpan
-	var start *trace.Event
+	var start *Event
 	for _, ev := range list {
 		if start != nil {
 			out = append(out, newTimeSpan(start, ev))
 			start = nil
 		}
 		switch ev.Type {
-		case trace.EvGoBlockNet:
+		case EvGoBlockNet:
 			start = ev
 		}
 	}
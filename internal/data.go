@@ -3,43 +3,46 @@ package internal
 import (
 	"fmt"
 	"sort"
-
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 )
 
 type Data struct {
 	// Result holds the event list from a basic execution trace parse result.
 	// The events are chronological order.
-	Events []*trace.Event
+	Events []*Event
 	// GoroutineEvents maps each observed goroutine to that goroutine's events.
-	GoroutineEvents map[uint64][]*trace.Event
+	GoroutineEvents map[uint64][]*Event
 	// GoroutineList lists all known goroutines by ID
 	GoroutineList []uint64
 	// Backlinks holds events that are the targets of other events' Link field.
-	Backlinks map[*trace.Event]*trace.Event
+	Backlinks map[*Event]*Event
 	// GoroutineRuns maps GoStart events to the sequence of events on that
 	// goroutine that followed, until the goroutine stopped running.
-	GoroutineRuns map[*trace.Event][]*trace.Event
+	GoroutineRuns map[*Event][]*Event
 
 	// Prev maps an event to the preceding event on the same goroutine.
-	Prev map[*trace.Event]*trace.Event
+	Prev map[*Event]*Event
 	// Next maps an event to the subsequent event on the same goroutine.
-	Next map[*trace.Event]*trace.Event
+	Next map[*Event]*Event
+
+	// StartNs is the timestamp of the first event in the execution trace,
+	// which may be earlier than the first of the Events. It's zero when
+	// unknown, as for Data from PrepareData.
+	StartNs int64
 }
 
-func PrepareData(events []*trace.Event) *Data {
+func PrepareData(events []*Event) *Data {
 	var data Data
-	data.Events = make([]*trace.Event, len(events))
+	data.Events = make([]*Event, len(events))
 	copy(data.Events, events)
 
 	// Sort events by timestamp
-	sort.Slice(data.Events, func(i, j int) bool {
+	sort.SliceStable(data.Events, func(i, j int) bool {
 		ei, ej := data.Events[i], data.Events[j]
 		return ei.Ts < ej.Ts
 	})
 
 	// Map goroutines to their events
-	data.GoroutineEvents = make(map[uint64][]*trace.Event)
+	data.GoroutineEvents = make(map[uint64][]*Event)
 	for _, ev := range data.Events {
 		data.GoroutineEvents[ev.G] = append(data.GoroutineEvents[ev.G], ev)
 	}
@@ -47,13 +50,13 @@ func PrepareData(events []*trace.Event) *Data {
 		data.GoroutineList = append(data.GoroutineList, g)
 	}
 	sort.Slice(data.GoroutineList, func(i, j int) bool { return data.GoroutineList[i] < data.GoroutineList[j] })
-	data.Prev = make(map[*trace.Event]*trace.Event)
-	data.Next = make(map[*trace.Event]*trace.Event)
+	data.Prev = make(map[*Event]*Event)
+	data.Next = make(map[*Event]*Event)
 	for _, g := range data.GoroutineList {
 		evs := data.GoroutineEvents[g]
-		sort.Slice(evs, func(i, j int) bool { return evs[i].Ts < evs[j].Ts })
+		sort.SliceStable(evs, func(i, j int) bool { return evs[i].Ts < evs[j].Ts })
 
-		var prev *trace.Event
+		var prev *Event
 		for _, ev := range evs {
 			if prev != nil {
 				data.Prev[ev] = prev
@@ -63,7 +66,7 @@ func PrepareData(events []*trace.Event) *Data {
 		}
 	}
 
-	data.Backlinks = make(map[*trace.Event]*trace.Event)
+	data.Backlinks = make(map[*Event]*Event)
 	for _, ev := range data.Events {
 		if ev.Link != nil {
 			if prev := data.Backlinks[ev.Link]; prev != nil {
@@ -73,12 +76,12 @@ func PrepareData(events []*trace.Event) *Data {
 		}
 	}
 
-	data.GoroutineRuns = make(map[*trace.Event][]*trace.Event)
+	data.GoroutineRuns = make(map[*Event][]*Event)
 	for _, g := range data.GoroutineList {
-		var start *trace.Event
+		var start *Event
 		// makes a copy of the events slice - maybe we could share
 		for _, ev := range data.GoroutineEvents[g] {
-			if ev.Type == trace.EvGoStart {
+			if ev.Type == EvGoStart {
 				start = ev
 			}
 			if start != nil {
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// An Event is a single goroutine's interaction with the scheduler (or other
+// runtime machinery), in the goroutine-centric style of the original execution
+// trace format.
+//
+// The Go 1.22+ execution trace format describes state transitions of
+// goroutines, Ps, and Ms. The region-finding and region-connecting code in this
+// package was built on the per-goroutine event sequences of the older format,
+// and those sequences are also what our text-based test data describe. So we
+// convert the newer format's events into this shape, and use it throughout.
+type Event struct {
+	// Ts is the time of the event, in nanoseconds.
+	Ts int64
+	// P is the id of the P that was executing the event, or -1.
+	P int
+	// G is the id of the goroutine the event describes. Events that don't
+	// belong to any goroutine (such as unblocking by the network poller) are
+	// assigned to goroutine 0.
+	G uint64
+	// Off is the event's position in the trace.
+	Off int
+	// Type describes the kind of event.
+	Type EventType
+	// Args holds event-type-specific values, described in EventDescriptions.
+	Args [3]uint64
+	// SArgs holds event-type-specific strings, such as the name of a user
+	// task or region, described in EventDescriptions.
+	SArgs []string
+	// Stk is the event's call stack, with the leaf frame first.
+	Stk []runtime.Frame
+	// Link points to a related event on another goroutine. For GoCreate and
+	// GoUnblock, that's the target goroutine's next GoStart. For the GoBlock
+	// family, that's the event that makes this goroutine runnable again.
+	Link *Event
+}
+
+type EventType uint8
+
+const (
+	EvNone EventType = iota
+	EvGoCreate
+	EvGoStart
+	EvGoEnd
+	EvGoSched
+	EvGoPreempt
+	EvGoSleep
+	EvGoBlock
+	EvGoUnblock
+	EvGoBlockSend
+	EvGoBlockRecv
+	EvGoBlockSelect
+	EvGoBlockSync
+	EvGoBlockCond
+	EvGoBlockNet
+	EvGoSysCall
+	EvGoSysExit
+	EvGoSysBlock
+	EvGoWaiting
+	EvGoInSyscall
+	EvHeapAlloc
+	EvGCMarkAssistStart
+	EvGCMarkAssistDone
+	EvGCSweepStart
+	EvGCSweepDone
+	EvGoBlockGC
+	EvCPUSample
+	EvUserTaskCreate
+	EvUserTaskEnd
+	EvUserRegion
+	EvCount
+)
+
+var EventDescriptions = [EvCount]struct {
+	Name  string
+	Args  []string
+	SArgs []string
+}{
+	EvNone:              {"None", nil, nil},
+	EvGoCreate:          {"GoCreate", []string{"g", "stack"}, nil},
+	EvGoStart:           {"GoStart", []string{"g", "seq"}, nil},
+	EvGoEnd:             {"GoEnd", nil, nil},
+	EvGoSched:           {"GoSched", nil, nil},
+	EvGoPreempt:         {"GoPreempt", nil, nil},
+	EvGoSleep:           {"GoSleep", nil, nil},
+	EvGoBlock:           {"GoBlock", nil, nil},
+	EvGoUnblock:         {"GoUnblock", []string{"g", "seq"}, nil},
+	EvGoBlockSend:       {"GoBlockSend", nil, nil},
+	EvGoBlockRecv:       {"GoBlockRecv", nil, nil},
+	EvGoBlockSelect:     {"GoBlockSelect", nil, nil},
+	EvGoBlockSync:       {"GoBlockSync", nil, nil},
+	EvGoBlockCond:       {"GoBlockCond", nil, nil},
+	EvGoBlockNet:        {"GoBlockNet", nil, nil},
+	EvGoSysCall:         {"GoSysCall", nil, nil},
+	EvGoSysExit:         {"GoSysExit", []string{"g", "seq", "ts"}, nil},
+	EvGoSysBlock:        {"GoSysBlock", nil, nil},
+	EvGoWaiting:         {"GoWaiting", []string{"g"}, nil},
+	EvGoInSyscall:       {"GoInSyscall", []string{"g"}, nil},
+	EvHeapAlloc:         {"HeapAlloc", []string{"mem"}, nil},
+	EvGCMarkAssistStart: {"GCMarkAssistStart", nil, nil},
+	EvGCMarkAssistDone:  {"GCMarkAssistDone", nil, nil},
+	EvGCSweepStart:      {"GCSweepStart", nil, nil},
+	EvGCSweepDone:       {"GCSweepDone", []string{"swept", "reclaimed"}, nil},
+	EvGoBlockGC:         {"GoBlockGC", nil, nil},
+	EvCPUSample:         {"CPUSample", nil, nil},
+	EvUserTaskCreate:    {"UserTaskCreate", []string{"taskid", "pid"}, []string{"name"}},
+	EvUserTaskEnd:       {"UserTaskEnd", []string{"taskid"}, nil},
+	EvUserRegion:        {"UserRegion", []string{"taskid", "mode"}, []string{"name"}},
+}
+
+func (t EventType) String() string {
+	if t < EvCount {
+		return EventDescriptions[t].Name
+	}
+	return fmt.Sprintf("EventType(%d)", uint8(t))
+}
+
+func (ev *Event) String() string {
+	desc := EventDescriptions[ev.Type]
+	w := new(strings.Builder)
+	fmt.Fprintf(w, "%d %s p=%d g=%d off=%d", ev.Ts, desc.Name, ev.P, ev.G, ev.Off)
+	for i, a := range desc.Args {
+		fmt.Fprintf(w, " %s=%d", a, ev.Args[i])
+	}
+	for i, a := range desc.SArgs {
+		var v string
+		if i < len(ev.SArgs) {
+			v = ev.SArgs[i]
+		}
+		fmt.Fprintf(w, " %s=%q", a, v)
+	}
+	return w.String()
+}
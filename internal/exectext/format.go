@@ -3,19 +3,20 @@ package exectext
 import (
 	"bufio"
 	"fmt"
+	"runtime"
 	"strconv"
 	"strings"
 
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
+	"github.com/rhysh/go-tracing-toolbox/internal"
 )
 
 type EventWriter struct {
 	Stack    bool
 	Link     bool
-	Backlink map[*trace.Event]*trace.Event
+	Backlink map[*internal.Event]*internal.Event
 }
 
-func (w *EventWriter) Format(ev *trace.Event) string {
+func (w *EventWriter) Format(ev *internal.Event) string {
 	var links []string
 	if from := w.Backlink[ev]; from != nil && ev.G != from.G {
 		links = append(links, fmt.Sprintf("from %s", from))
@@ -32,21 +33,21 @@ func (w *EventWriter) Format(ev *trace.Event) string {
 	fmt.Fprintf(&b, "\n")
 	if w.Stack {
 		for _, frame := range ev.Stk {
-			fmt.Fprintf(&b, "  %x %s %s:%d\n", frame.PC, frame.Fn, frame.File, frame.Line)
+			fmt.Fprintf(&b, "  %x %s %s:%d\n", frame.PC, frame.Function, frame.File, frame.Line)
 		}
 	}
 	return b.String()
 }
 
-func ParseEvents(str string) ([]*trace.Event, error) {
+func ParseEvents(str string) ([]*internal.Event, error) {
 	type evKey struct {
 		Ts int64
 		G  uint64
 	}
-	evm := make(map[evKey]*trace.Event)
-	links := make(map[*trace.Event]evKey)
+	evm := make(map[evKey]*internal.Event)
+	links := make(map[*internal.Event]evKey)
 
-	var evs []*trace.Event
+	var evs []*internal.Event
 	for _, s := range splitEvents(str) {
 		ev, to, err := parseEventString(s)
 		if err != nil {
@@ -96,7 +97,7 @@ func splitEvents(s string) []string {
 	return evs
 }
 
-func parseEventString(s string) (*trace.Event, string, error) {
+func parseEventString(s string) (*internal.Event, string, error) {
 	s = strings.TrimSuffix(s, "\n")
 	lines := strings.Split(s, "\n")
 	title, stack := lines[0], lines[1:]
@@ -109,21 +110,21 @@ func parseEventString(s string) (*trace.Event, string, error) {
 		if err != nil {
 			return ev, to, err
 		}
-		ev.Stk = append(ev.Stk, frame)
+		ev.Stk = append(ev.Stk, *frame)
 	}
 	return ev, to, nil
 }
 
-func parseEventTitle(str string) (*trace.Event, string, error) {
-	ev := &trace.Event{}
+func parseEventTitle(str string) (*internal.Event, string, error) {
+	ev := &internal.Event{}
 	parts := strings.SplitN(str, " ", 6)
 	if len(parts) < 5 {
 		return nil, "", fmt.Errorf("need at least five parts for event")
 	}
 
-	for i, desc := range trace.EventDescriptions {
+	for i, desc := range internal.EventDescriptions {
 		if parts[1] == desc.Name {
-			ev.Type = uint8(i)
+			ev.Type = internal.EventType(i)
 			break
 		}
 	}
@@ -162,7 +163,7 @@ func parseEventTitle(str string) (*trace.Event, string, error) {
 	ev.G = getUint(trimPrefix(parts[3], "g="), 10, 64)
 	ev.Off = int(getInt(trimPrefix(parts[4], "off="), 10, 0))
 
-	desc := trace.EventDescriptions[ev.Type]
+	desc := internal.EventDescriptions[ev.Type]
 	args := strings.Join(parts[5:], " ")
 	for i, k := range desc.Args {
 		parts := strings.SplitN(args, " ", 2)
@@ -172,6 +173,19 @@ func parseEventTitle(str string) (*trace.Event, string, error) {
 			ev.Args[i] = getUint(trimPrefix(arg, k+"="), 10, 64)
 		}
 	}
+	for _, k := range desc.SArgs {
+		// String args are quoted, and may include spaces.
+		v := trimPrefix(args, k+"=")
+		var quoted, arg string
+		if err == nil {
+			quoted, err = strconv.QuotedPrefix(v)
+		}
+		if err == nil {
+			arg, err = strconv.Unquote(quoted)
+		}
+		ev.SArgs = append(ev.SArgs, arg)
+		args = strings.TrimPrefix(v[len(quoted):], " ")
+	}
 
 	var to string
 	if strings.HasPrefix(args, "(") && strings.HasSuffix(args, ")") {
@@ -191,8 +205,6 @@ func parseEventTitle(str string) (*trace.Event, string, error) {
 		err = fmt.Errorf("extra args %q", args)
 	}
 
-	// TODO: string args (for EvUserLog and EvUserTaskCreate)
-
 	if err != nil {
 		return nil, "", fmt.Errorf("parseEventTitle %q: %w", str, err)
 	}
@@ -200,8 +212,8 @@ func parseEventTitle(str string) (*trace.Event, string, error) {
 	return ev, to, nil
 }
 
-func parseEventFrame(s string) (*trace.Frame, error) {
-	frame := &trace.Frame{}
+func parseEventFrame(s string) (*runtime.Frame, error) {
+	frame := &runtime.Frame{}
 	parts := strings.SplitN(s, " ", 5)
 	if len(parts) != 5 {
 		return nil, fmt.Errorf("need five parts per frame")
@@ -215,9 +227,9 @@ func parseEventFrame(s string) (*trace.Frame, error) {
 	if err != nil {
 		return nil, fmt.Errorf("expect hex pc: %w", err)
 	}
-	frame.PC = uint64(pc)
+	frame.PC = uintptr(pc)
 
-	frame.Fn = parts[3]
+	frame.Function = parts[3]
 
 	i := strings.LastIndex(parts[4], ":")
 	if i < 0 {
@@ -18,6 +18,11 @@ func TestParse(t *testing.T) {
 20 GoUnblock p=0 g=100 off=4 g=42 seq=0 (to 22 GoStart p=0 g=42 off=6 g=42 seq=0)
 21 GoEnd p=0 g=100 off=5
 22 GoStart p=0 g=42 off=6 g=42 seq=0
+23 UserTaskCreate p=0 g=42 off=7 taskid=1 pid=0 name="handle request"
+  10face pkg.execute /home/gopher/src/pkg/somewhere.go:3
+24 UserRegion p=0 g=42 off=8 taskid=1 mode=0 name="say \"hi\""
+25 UserRegion p=0 g=42 off=9 taskid=1 mode=1 name="say \"hi\""
+26 UserTaskEnd p=0 g=42 off=10 taskid=1
 `[1:]
 
 	evs, err := exectext.ParseEvents(str)
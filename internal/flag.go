@@ -6,16 +6,23 @@ import (
 	"io"
 	"strings"
 
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
+	"github.com/rhysh/go-tracing-toolbox/internal/match2"
 )
 
 type StackFlag struct {
-	Event byte
+	Event EventType // Use 0 ("EvNone") to match everything
 	Specs []string
 }
 
-func (sf *StackFlag) EventMatches(t byte) bool {
-	return sf.Event == 0xFF || sf.Event == t
+func (sf *StackFlag) EventMatches(t EventType) bool {
+	return sf.Event == EvNone || sf.Event == t
+}
+
+// Matches returns whether the event has a matching type and call stack. As
+// with match2.HasStackRe, an empty list of Specs matches only events that have
+// no call stack.
+func (sf *StackFlag) Matches(ev *Event) bool {
+	return sf.EventMatches(ev.Type) && match2.HasStackRe(ev.Stk, sf.Specs...)
 }
 
 var _ flag.Value = (*StackFlag)(nil)
@@ -28,10 +35,10 @@ func (sf *StackFlag) String() string {
 	var buf strings.Builder
 
 	name := ""
-	if sf.Event == 0xFF {
+	if sf.Event == EvNone {
 		name = "Any"
 	} else {
-		name = trace.EventDescriptions[sf.Event].Name
+		name = sf.Event.String()
 	}
 	fmt.Fprintf(&buf, "%s", name)
 	for _, fn := range sf.Specs {
@@ -44,21 +51,22 @@ func (sf *StackFlag) String() string {
 }
 
 func (sf *StackFlag) Set(v string) error {
-	sf.Event = 0
+	sf.Event = EvNone
 	sf.Specs = nil
 
 	parts := strings.SplitN(v, " ", 2)
-	for id, desc := range trace.EventDescriptions {
-		if desc.Name == parts[0] {
-			sf.Event = byte(id)
+	for typ := EvNone + 1; typ < EvCount; typ++ {
+		if typ.String() == parts[0] {
+			sf.Event = typ
 			break
 		}
 	}
-	if parts[0] == "Any" {
-		sf.Event = 0xFF
-	}
-	if sf.Event == 0 {
-		return fmt.Errorf("invalid trace event name %q", parts[0])
+	if sf.Event == EvNone {
+		if parts[0] == "Any" {
+			// leave as 0 / EvNone
+		} else {
+			return fmt.Errorf("invalid trace event name %q", parts[0])
+		}
 	}
 
 	if len(parts) == 1 {
@@ -77,7 +85,7 @@ func (sf *StackFlag) Set(v string) error {
 	}
 
 	// Verify the stack-matching regular expressions (and memoize the compiled regexps)
-	_, err := globalProgram.hasStackRe(nil, sf.Specs...)
+	err := match2.ValidateRe(sf.Specs...)
 	if err != nil {
 		return fmt.Errorf("invalid stack matcher flag: %w", err)
 	}
@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/rhysh/go-tracing-toolbox/internal/match2"
@@ -12,6 +14,15 @@ import (
 
 type StackFlag struct {
 	Event trace.EventKind // Use 0 ("EventBad") to match everything
+
+	// From and To restrict a StateTransition event to goroutines that move
+	// out of and into the named states, such as "Running" and "Waiting". An
+	// empty string matches any state.
+	From, To string
+	// Reason, when not nil, restricts a StateTransition event to goroutine
+	// transitions with this reason, such as "sync" or "chan receive".
+	Reason *string
+
 	Specs []string
 }
 
@@ -19,6 +30,39 @@ func (sf *StackFlag) EventMatches(t trace.EventKind) bool {
 	return sf.Event == trace.EventBad || sf.Event == t
 }
 
+// TransitionMatches reports whether ev meets the StackFlag's requirements for
+// goroutine state transitions. Events are only subject to those requirements
+// when they are present.
+func (sf *StackFlag) TransitionMatches(ev trace.Event) bool {
+	if sf.From == "" && sf.To == "" && sf.Reason == nil {
+		return true
+	}
+	if ev.Kind() != trace.EventStateTransition {
+		return false
+	}
+	st := ev.StateTransition()
+	if st.Resource.Kind != trace.ResourceGoroutine {
+		return false
+	}
+	from, to := st.Goroutine()
+	if sf.From != "" && sf.From != from.String() {
+		return false
+	}
+	if sf.To != "" && sf.To != to.String() {
+		return false
+	}
+	if sf.Reason != nil && *sf.Reason != st.Reason {
+		return false
+	}
+	return true
+}
+
+// Matches reports whether ev, with call stack stk, meets all of the
+// StackFlag's requirements.
+func (sf *StackFlag) Matches(ev trace.Event, stk []runtime.Frame) bool {
+	return sf.EventMatches(ev.Kind()) && sf.TransitionMatches(ev) && match2.HasStackRe(stk, sf.Specs...)
+}
+
 var _ flag.Value = (*StackFlag)(nil)
 
 func (sf *StackFlag) String() string {
@@ -35,6 +79,12 @@ func (sf *StackFlag) String() string {
 		name = sf.Event.String()
 	}
 	fmt.Fprintf(&buf, "%s", name)
+	if sf.From != "" || sf.To != "" {
+		fmt.Fprintf(&buf, " %s->%s", anyState(sf.From), anyState(sf.To))
+	}
+	if sf.Reason != nil {
+		fmt.Fprintf(&buf, " reason=%q", *sf.Reason)
+	}
 	for _, fn := range sf.Specs {
 		fmt.Fprintf(&buf, " %q", fn)
 	}
@@ -45,8 +95,7 @@ func (sf *StackFlag) String() string {
 }
 
 func (sf *StackFlag) Set(v string) error {
-	sf.Event = trace.EventBad
-	sf.Specs = nil
+	*sf = StackFlag{}
 
 	parts := strings.SplitN(v, " ", 2)
 	for kind := trace.EventKind(1); kind != trace.EventBad; kind++ {
@@ -69,7 +118,42 @@ func (sf *StackFlag) Set(v string) error {
 	if len(parts) == 1 {
 		return nil
 	}
-	specs := strings.NewReader(parts[1])
+
+	// Before the stack patterns, a StateTransition matcher may list the
+	// states and the reason, as in `Running->Waiting reason="sync"`.
+	rest := strings.TrimLeft(parts[1], " ")
+	for rest != "" && !strings.HasPrefix(rest, `"`) {
+		if sf.Event != trace.EventStateTransition {
+			return fmt.Errorf("only StateTransition events may match states, found %q", rest)
+		}
+		if after, ok := strings.CutPrefix(rest, "reason="); ok {
+			quoted, err := strconv.QuotedPrefix(after)
+			if err != nil {
+				return fmt.Errorf("invalid reason in %q: %w", rest, err)
+			}
+			reason, _ := strconv.Unquote(quoted)
+			sf.Reason = &reason
+			rest = strings.TrimLeft(after[len(quoted):], " ")
+			continue
+		}
+		word, after, _ := strings.Cut(rest, " ")
+		from, to, ok := strings.Cut(word, "->")
+		if !ok {
+			return fmt.Errorf("expected states like %q, found %q", "Running->Waiting", word)
+		}
+		var err error
+		sf.From, err = parseState(from)
+		if err != nil {
+			return err
+		}
+		sf.To, err = parseState(to)
+		if err != nil {
+			return err
+		}
+		rest = strings.TrimLeft(after, " ")
+	}
+
+	specs := strings.NewReader(rest)
 	for {
 		var s string
 		_, err := fmt.Fscanf(specs, "%q", &s)
@@ -88,3 +172,64 @@ func (sf *StackFlag) Set(v string) error {
 	}
 	return nil
 }
+
+// anyState formats a state for a StackFlag, where "*" matches any state.
+func anyState(state string) string {
+	if state == "" {
+		return "*"
+	}
+	return state
+}
+
+// parseState checks that name is the name of a goroutine state, or "*" to
+// match any state. It returns the name, or "" for any state.
+func parseState(name string) (string, error) {
+	if name == "*" || name == "" {
+		return "", nil
+	}
+	for _, state := range []trace.GoState{
+		trace.GoUndetermined, trace.GoNotExist, trace.GoRunnable,
+		trace.GoRunning, trace.GoWaiting, trace.GoSyscall,
+	} {
+		if state.String() == name {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid goroutine state %q", name)
+}
+
+// A StackFlagList is a StackFlag that may be provided several times.
+type StackFlagList []*StackFlag
+
+var _ flag.Value = (*StackFlagList)(nil)
+
+func (l *StackFlagList) String() string {
+	if l == nil {
+		return ""
+	}
+	var strs []string
+	for _, sf := range *l {
+		strs = append(strs, sf.String())
+	}
+	return strings.Join(strs, "; ")
+}
+
+func (l *StackFlagList) Set(v string) error {
+	sf := new(StackFlag)
+	err := sf.Set(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, sf)
+	return nil
+}
+
+// Any reports whether any of the StackFlags match ev, with call stack stk.
+func (l StackFlagList) Any(ev trace.Event, stk []runtime.Frame) bool {
+	for _, sf := range l {
+		if sf.Matches(ev, stk) {
+			return true
+		}
+	}
+	return false
+}
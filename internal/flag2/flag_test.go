@@ -51,4 +51,33 @@ func TestFlag(t *testing.T) {
 
 	t.Run("", badcase(`StateTransition oops`))
 	t.Run("", badcase(`StateTransition "["`))
+
+	t.Run("", roundtripcase(`StateTransition Running->Waiting "**"`))
+	t.Run("", roundtripcase(`StateTransition *->Runnable "**"`))
+	t.Run("", roundtripcase(`StateTransition Running->Waiting reason="chan receive" "**" "^sync...Mutex..Lock$"`))
+	t.Run("", roundtripcase(`StateTransition reason="sync" "**"`))
+	t.Run("", goodcase(`StateTransition Running->*`))
+
+	t.Run("", badcase(`StateTransition Running->Sleeping "**"`))
+	t.Run("", badcase(`StateTransition reason=sync "**"`))
+	t.Run("", badcase(`Metric Running->Waiting "**"`))
+}
+
+func TestFlagList(t *testing.T) {
+	var l flag2.StackFlagList
+	for _, v := range []string{`StateTransition "**"`, `Any "**" "^log\\."`} {
+		err := l.Set(v)
+		if err != nil {
+			t.Fatalf("StackFlagList.Set(%q); err = %v", v, err)
+		}
+	}
+	if have, want := l.String(), `StateTransition "**"; Any "**" "^log\\."`; have != want {
+		t.Errorf("StackFlagList.String() = %q, want %q", have, want)
+	}
+	if err := l.Set("Foo"); err == nil {
+		t.Errorf("StackFlagList.Set(%q); err = nil", "Foo")
+	}
+	if len(l) != 2 {
+		t.Errorf("len(StackFlagList) = %d, want 2", len(l))
+	}
 }
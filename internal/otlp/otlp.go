@@ -0,0 +1,209 @@
+// Package otlp converts trees of Spans into OpenTelemetry traces, in the JSON
+// encoding of the OTLP protocol.
+//
+// Each TreeSummary becomes its own trace, with a span for the root and for
+// each of its Caused descendants. That allows comparing the work that the
+// execution trace shows with the spans that a distributed tracing system
+// records for the same requests.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+)
+
+// The types below follow the OTLP/JSON encoding of the messages in
+// opentelemetry/proto/trace/v1/trace.proto. The encoding uses lowerCamelCase
+// field names, hex for trace and span ids, and strings for 64-bit integers.
+
+type TracesData struct {
+	ResourceSpans []*ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource      `json:"resource"`
+	ScopeSpans []*ScopeSpans `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type ScopeSpans struct {
+	Scope Scope   `json:"scope"`
+	Spans []*Span `json:"spans"`
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+type Span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func stringAttr(key, v string) KeyValue {
+	return KeyValue{Key: key, Value: AnyValue{StringValue: &v}}
+}
+
+func intAttr(key string, v int64) KeyValue {
+	s := strconv.FormatInt(v, 10)
+	return KeyValue{Key: key, Value: AnyValue{IntValue: &s}}
+}
+
+// ScopeName identifies this package as the instrumentation scope of the spans
+// it creates.
+const ScopeName = "github.com/rhysh/go-tracing-toolbox"
+
+// Convert creates an OTLP trace for each TreeSummary. The serviceName becomes
+// the "service.name" resource attribute. The offsetNs value converts the
+// execution trace's timestamps to Unix time; see internal.ReadClockOffset.
+//
+// Every span has attributes for its Kind and goroutine. The root span also
+// has the summary's flat breakdown of wall-clock time into running, assist,
+// and wait reasons, as in "go.flat.wait.net_ns".
+//
+// The trace and span ids derive from the spans' goroutines and start times, so
+// converting the same summaries again gives the same ids.
+func Convert(summaries []*cluster.TreeSummary, serviceName string, offsetNs int64) *TracesData {
+	scope := &ScopeSpans{Scope: Scope{Name: ScopeName}}
+	for _, summary := range summaries {
+		root := summary.Root
+		traceID := newID(16, "trace", root.G, root.StartNs)
+
+		var visit func(span *cluster.Span, parentID string, path string)
+		visit = func(span *cluster.Span, parentID string, path string) {
+			spanID := newID(8, path, span.G, span.StartNs)
+			out := &Span{
+				TraceID:           traceID,
+				SpanID:            spanID,
+				ParentSpanID:      parentID,
+				Name:              span.Kind,
+				Kind:              spanKind(span.Kind),
+				StartTimeUnixNano: strconv.FormatInt(span.StartNs+offsetNs, 10),
+				EndTimeUnixNano:   strconv.FormatInt(span.StartNs+span.LengthNs+offsetNs, 10),
+				Attributes: []KeyValue{
+					stringAttr("go.region.kind", span.Kind),
+					intAttr("go.goroutine.id", int64(span.G)),
+				},
+			}
+			if span == root {
+				out.Attributes = append(out.Attributes, flatAttrs(summary)...)
+			}
+			scope.Spans = append(scope.Spans, out)
+
+			for i, child := range span.Caused {
+				visit(child, spanID, fmt.Sprintf("%s/%d", path, i))
+			}
+		}
+		visit(root, "", traceID)
+	}
+
+	return &TracesData{ResourceSpans: []*ResourceSpans{{
+		Resource:   Resource{Attributes: []KeyValue{stringAttr("service.name", serviceName)}},
+		ScopeSpans: []*ScopeSpans{scope},
+	}}}
+}
+
+func flatAttrs(summary *cluster.TreeSummary) []KeyValue {
+	attrs := []KeyValue{
+		intAttr("go.flat.run_ns", summary.FlatRunNs),
+		intAttr("go.total.run_ns", summary.TotalRunNs),
+	}
+	for _, pair := range []struct {
+		prefix string
+		m      map[string]int64
+	}{
+		{"go.flat.assist.", summary.FlatAssistNs},
+		{"go.flat.wait.", summary.FlatWaitNs},
+	} {
+		var reasons []string
+		for reason := range pair.m {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			attrs = append(attrs, intAttr(pair.prefix+reason+"_ns", pair.m[reason]))
+		}
+	}
+	return attrs
+}
+
+// spanKind follows the naming convention of the built-in patterns, where
+// Kinds like "server/http" describe handling inbound requests and Kinds like
+// "client/grpc" describe making outbound ones.
+func spanKind(kind string) SpanKind {
+	switch {
+	case strings.HasPrefix(kind, "server/"):
+		return SpanKindServer
+	case strings.HasPrefix(kind, "client/"):
+		return SpanKindClient
+	}
+	return SpanKindInternal
+}
+
+// newID returns a hex-encoded id of n bytes, derived from the inputs.
+func newID(n int, path string, g uint64, startNs int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %d %d", path, g, startNs)))
+	return hex.EncodeToString(sum[:n])
+}
+
+// Post sends the traces to an OTLP/HTTP endpoint that accepts JSON, such as
+// "http://localhost:4318/v1/traces" for a local OpenTelemetry Collector.
+func Post(ctx context.Context, endpoint string, td *TracesData) error {
+	body, err := json.Marshal(td)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %s: %s: %s", endpoint, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package otlp_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+	"github.com/rhysh/go-tracing-toolbox/internal/otlp"
+	"github.com/rhysh/go-tracing-toolbox/internal/pattern"
+	"github.com/rhysh/go-tracing-toolbox/internal/testhelp"
+)
+
+func attrs(span *otlp.Span) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range span.Attributes {
+		switch {
+		case kv.Value.StringValue != nil:
+			m[kv.Key] = *kv.Value.StringValue
+		case kv.Value.IntValue != nil:
+			m[kv.Key] = *kv.Value.IntValue
+		}
+	}
+	return m
+}
+
+func TestConvert(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/sql_pool")
+
+	var summaries []*cluster.TreeSummary
+	for _, span := range cluster.ExtractSpans(data, pattern.TrackAll) {
+		summaries = append(summaries, cluster.Summarize(span))
+	}
+
+	td := otlp.Convert(summaries, "test", 1_000_000_000)
+	spans := td.ResourceSpans[0].ScopeSpans[0].Spans
+
+	var count int
+	for _, summary := range summaries {
+		cluster.Visit(summary.Root, func(*cluster.Span) { count++ })
+	}
+	if have, want := len(spans), count; have != want {
+		t.Fatalf("found %d spans, expected %d", have, want)
+	}
+
+	// Each parent is in the same trace, and appears before its children.
+	seen := make(map[string]*otlp.Span)
+	traces := make(map[string]bool)
+	for _, span := range spans {
+		if _, ok := seen[span.SpanID]; ok {
+			t.Errorf("duplicate span id %q", span.SpanID)
+		}
+		seen[span.SpanID] = span
+		if span.ParentSpanID == "" {
+			if traces[span.TraceID] {
+				t.Errorf("duplicate trace id %q", span.TraceID)
+			}
+			traces[span.TraceID] = true
+			continue
+		}
+		parent, ok := seen[span.ParentSpanID]
+		if !ok {
+			t.Errorf("span %q has unknown parent %q", span.SpanID, span.ParentSpanID)
+			continue
+		}
+		if parent.TraceID != span.TraceID {
+			t.Errorf("span %q is in trace %q, but its parent is in %q", span.SpanID, span.TraceID, parent.TraceID)
+		}
+	}
+	if have, want := len(traces), len(summaries); have != want {
+		t.Errorf("found %d traces, expected %d", have, want)
+	}
+
+	// The root query span from TestSQLPoolWait in the cluster package.
+	var root *otlp.Span
+	for _, span := range spans {
+		if span.ParentSpanID == "" && span.StartTimeUnixNano == "1831402183616" {
+			root = span
+		}
+	}
+	if root == nil {
+		t.Fatalf("could not find root span")
+	}
+	if have, want := root.Kind, otlp.SpanKindClient; have != want {
+		t.Errorf("kind; %d != %d", have, want)
+	}
+	a := attrs(root)
+	for k, want := range map[string]string{
+		"go.region.kind":         "client/sql_query",
+		"go.goroutine.id":        "11",
+		"go.flat.wait.cpu_ns":    "3200",
+		"go.flat.wait.dbpool_ns": "7517696",
+		"go.flat.wait.sleep_ns":  "1078080",
+	} {
+		if have := a[k]; have != want {
+			t.Errorf("attribute %q; %q != %q", k, have, want)
+		}
+	}
+
+	// Converting again gives the same ids.
+	again := otlp.Convert(summaries, "test", 1_000_000_000)
+	if !reflect.DeepEqual(td, again) {
+		t.Errorf("Convert is not deterministic")
+	}
+}
+
+func TestPost(t *testing.T) {
+	var have otlp.TracesData
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type; %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &have); err != nil {
+			t.Errorf("json.Unmarshal; err = %v", err)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	summary := cluster.Summarize(&cluster.Span{G: 1, Kind: "server/http", StartNs: 100, LengthNs: 10, StartRun: []int64{0}})
+	td := otlp.Convert([]*cluster.TreeSummary{summary}, "test", 0)
+	if err := otlp.Post(context.Background(), srv.URL+"/v1/traces", td); err != nil {
+		t.Fatalf("Post; err = %v", err)
+	}
+	if !reflect.DeepEqual(&have, td) {
+		t.Errorf("Post sent %+v, expected %+v", have, td)
+	}
+}
@@ -0,0 +1,315 @@
+package internal
+
+import (
+	"io"
+	"runtime"
+
+	"golang.org/x/exp/trace"
+)
+
+// ReadTrace parses an execution trace in the v2 format (from Go 1.22 and
+// newer), and converts its contents into a list of goroutine-centric Events in
+// chronological order.
+func ReadTrace(r io.Reader) ([]*Event, error) {
+	evs, _, err := readTrace(r)
+	return evs, err
+}
+
+// ReadTraceData parses an execution trace as ReadTrace does, and prepares its
+// Events with PrepareData. It also records when the trace began, in StartNs.
+func ReadTraceData(r io.Reader) (*Data, error) {
+	evs, start, err := readTrace(r)
+	if err != nil {
+		return nil, err
+	}
+	data := PrepareData(evs)
+	data.StartNs = start
+	return data, nil
+}
+
+// readTrace returns the converted Events, and the timestamp of the first
+// event in the trace. The converter skips some events, such as Sync, so the
+// trace can begin well before the first Event.
+func readTrace(r io.Reader) (evs []*Event, start int64, err error) {
+	reader, err := trace.NewReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	c := newConverter()
+	first := true
+	for {
+		ev, err := reader.ReadEvent()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if first {
+			start = int64(ev.Time())
+			first = false
+		}
+		c.process(ev)
+	}
+	return c.events, start, nil
+}
+
+// ReadClockOffset reads an execution trace until it finds a snapshot of the
+// system's wall clock, and returns the number of nanoseconds to add to the
+// trace's timestamps (as in Event.Ts) to convert them to Unix time. Traces from
+// before Go 1.25 don't include a snapshot, which ok reports.
+func ReadClockOffset(r io.Reader) (offset int64, ok bool, err error) {
+	reader, err := trace.NewReader(r)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for {
+		ev, err := reader.ReadEvent()
+		if err == io.EOF {
+			return 0, false, nil
+		} else if err != nil {
+			return 0, false, err
+		}
+		if ev.Kind() != trace.EventSync {
+			continue
+		}
+		if snap := ev.Sync().ClockSnapshot; snap != nil {
+			return snap.Wall.UnixNano() - int64(snap.Trace), true, nil
+		}
+	}
+}
+
+type converter struct {
+	events []*Event
+	off    int
+
+	// startStack holds the entry stack of each newly-created goroutine, to
+	// attach to that goroutine's first GoStart event.
+	startStack map[uint64][]runtime.Frame
+	// pendingStart holds the GoCreate or GoUnblock event that made each
+	// goroutine runnable, to link to that goroutine's next GoStart event.
+	pendingStart map[uint64]*Event
+	// blocked holds the event that describes why each goroutine stopped
+	// running, to link to the event that allows it to run again.
+	blocked map[uint64]*Event
+	// syscall holds the goroutine that is in a syscall on each P, so we can
+	// notice when the P is taken away and the syscall becomes a blocking one.
+	syscall map[trace.ProcID]uint64
+}
+
+func newConverter() *converter {
+	return &converter{
+		startStack:   make(map[uint64][]runtime.Frame),
+		pendingStart: make(map[uint64]*Event),
+		blocked:      make(map[uint64]*Event),
+		syscall:      make(map[trace.ProcID]uint64),
+	}
+}
+
+func (c *converter) add(ev trace.Event, typ EventType, g uint64, stk trace.Stack) *Event {
+	c.off++
+	out := &Event{
+		Ts:   int64(ev.Time()),
+		P:    int(ev.Proc()),
+		G:    g,
+		Off:  c.off,
+		Type: typ,
+		Stk:  stackFrames(stk),
+	}
+	c.events = append(c.events, out)
+	return out
+}
+
+func (c *converter) process(ev trace.Event) {
+	switch ev.Kind() {
+	case trace.EventStateTransition:
+		st := ev.StateTransition()
+		switch st.Resource.Kind {
+		case trace.ResourceGoroutine:
+			c.goroutineTransition(ev, st)
+		case trace.ResourceProc:
+			c.procTransition(ev, st)
+		}
+	case trace.EventRangeBegin, trace.EventRangeEnd:
+		r := ev.Range()
+		if r.Name != "GC mark assist" || r.Scope.Kind != trace.ResourceGoroutine {
+			return
+		}
+		typ := EvGCMarkAssistStart
+		if ev.Kind() == trace.EventRangeEnd {
+			typ = EvGCMarkAssistDone
+		}
+		c.add(ev, typ, goroutineID(r.Scope.Goroutine()), trace.NoStack)
+	case trace.EventStackSample:
+		c.add(ev, EvCPUSample, goroutineID(ev.Goroutine()), ev.Stack())
+	case trace.EventTaskBegin:
+		t := ev.Task()
+		create := c.add(ev, EvUserTaskCreate, goroutineID(ev.Goroutine()), ev.Stack())
+		create.Args[0] = taskID(t.ID)
+		create.Args[1] = taskID(t.Parent)
+		create.SArgs = []string{t.Type}
+	case trace.EventTaskEnd:
+		c.add(ev, EvUserTaskEnd, goroutineID(ev.Goroutine()), ev.Stack()).Args[0] = taskID(ev.Task().ID)
+	case trace.EventRegionBegin, trace.EventRegionEnd:
+		// As in the original trace format, mode 0 marks the beginning of a
+		// region and mode 1 marks its end.
+		r := ev.Region()
+		region := c.add(ev, EvUserRegion, goroutineID(ev.Goroutine()), ev.Stack())
+		region.Args[0] = taskID(r.Task)
+		if ev.Kind() == trace.EventRegionEnd {
+			region.Args[1] = 1
+		}
+		region.SArgs = []string{r.Type}
+	}
+}
+
+func (c *converter) goroutineTransition(ev trace.Event, st trace.StateTransition) {
+	g := goroutineID(st.Resource.Goroutine())
+	from, to := st.Goroutine()
+
+	switch {
+	case from == trace.GoNotExist && (to == trace.GoRunnable || to == trace.GoWaiting):
+		create := c.add(ev, EvGoCreate, goroutineID(ev.Goroutine()), ev.Stack())
+		create.Args[0] = g
+		c.startStack[g] = stackFrames(st.Stack)
+		if to == trace.GoRunnable {
+			c.pendingStart[g] = create
+		}
+
+	case from == trace.GoUndetermined:
+		// The goroutine existed before the trace (or this part of it) began.
+		switch to {
+		case trace.GoRunning:
+			// It was already running, so its first Region needs a GoStart
+			// event to begin with.
+			c.add(ev, EvGoStart, g, trace.NoStack).Args[0] = g
+		case trace.GoWaiting:
+			c.add(ev, EvGoWaiting, g, trace.NoStack).Args[0] = g
+		case trace.GoSyscall:
+			c.add(ev, EvGoInSyscall, g, trace.NoStack).Args[0] = g
+		}
+
+	case from == trace.GoRunnable && to == trace.GoRunning:
+		start := c.add(ev, EvGoStart, g, trace.NoStack)
+		start.Args[0] = g
+		if stk, ok := c.startStack[g]; ok {
+			start.Stk = stk
+			delete(c.startStack, g)
+		}
+		if src, ok := c.pendingStart[g]; ok {
+			src.Link = start
+			delete(c.pendingStart, g)
+		}
+
+	case from == trace.GoRunning && to == trace.GoWaiting:
+		c.blocked[g] = c.add(ev, blockType(st.Reason), g, st.Stack)
+
+	case from == trace.GoWaiting && to == trace.GoRunnable:
+		unblock := c.add(ev, EvGoUnblock, goroutineID(ev.Goroutine()), ev.Stack())
+		unblock.Args[0] = g
+		if block, ok := c.blocked[g]; ok {
+			block.Link = unblock
+			delete(c.blocked, g)
+		}
+		c.pendingStart[g] = unblock
+
+	case from == trace.GoRunning && to == trace.GoRunnable:
+		typ := EvGoPreempt
+		if st.Reason == "Gosched" {
+			typ = EvGoSched
+		}
+		c.add(ev, typ, g, st.Stack)
+
+	case from == trace.GoRunning && to == trace.GoNotExist:
+		c.add(ev, EvGoEnd, g, trace.NoStack)
+
+	case from == trace.GoRunning && to == trace.GoSyscall:
+		c.add(ev, EvGoSysCall, g, st.Stack)
+		c.syscall[ev.Proc()] = g
+
+	case from == trace.GoSyscall:
+		for p, sg := range c.syscall {
+			if sg == g {
+				delete(c.syscall, p)
+			}
+		}
+		if to == trace.GoRunnable {
+			exit := c.add(ev, EvGoSysExit, g, trace.NoStack)
+			exit.Args[0] = g
+			if block, ok := c.blocked[g]; ok {
+				block.Link = exit
+				delete(c.blocked, g)
+			}
+		}
+	}
+}
+
+func (c *converter) procTransition(ev trace.Event, st trace.StateTransition) {
+	p := st.Resource.Proc()
+	_, to := st.Proc()
+	if to.Executing() {
+		return
+	}
+	g, ok := c.syscall[p]
+	if !ok {
+		return
+	}
+	// The goroutine's syscall took long enough that its P went on to other
+	// work (or to idle).
+	delete(c.syscall, p)
+	c.blocked[g] = c.add(ev, EvGoSysBlock, g, trace.NoStack)
+}
+
+// blockType converts the reason for a goroutine's Running->Waiting transition
+// into the corresponding EventType.
+func blockType(reason string) EventType {
+	switch reason {
+	case "network":
+		return EvGoBlockNet
+	case "select":
+		return EvGoBlockSelect
+	case "sync.(*Cond).Wait":
+		return EvGoBlockCond
+	case "sync":
+		return EvGoBlockSync
+	case "chan send":
+		return EvGoBlockSend
+	case "chan receive":
+		return EvGoBlockRecv
+	case "sleep":
+		return EvGoSleep
+	case "GC mark assist wait for work", "wait until GC ends", "GC weak to strong wait":
+		return EvGoBlockGC
+	}
+	return EvGoBlock
+}
+
+func goroutineID(goid trace.GoID) uint64 {
+	if goid == trace.NoGoroutine {
+		return 0
+	}
+	return uint64(goid)
+}
+
+// taskID converts a user task id, using 0 for the lack of a task (as the
+// original trace format did) rather than trace.NoTask.
+func taskID(id trace.TaskID) uint64 {
+	if id == trace.NoTask {
+		return 0
+	}
+	return uint64(id)
+}
+
+func stackFrames(stk trace.Stack) []runtime.Frame {
+	var frames []runtime.Frame
+	for f := range stk.Frames() {
+		frames = append(frames, runtime.Frame{
+			Function: f.Func,
+			File:     f.File,
+			Line:     int(f.Line),
+			PC:       uintptr(f.PC),
+		})
+	}
+	return frames
+}
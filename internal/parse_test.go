@@ -0,0 +1,122 @@
+package internal_test
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rhysh/go-tracing-toolbox/internal"
+)
+
+func TestReadTrace(t *testing.T) {
+	f, err := os.Open("../testdata/go1.27.1/trace_events/events.trace")
+	if err != nil {
+		t.Fatalf("Open; err = %v", err)
+	}
+	defer f.Close()
+	data, err := internal.ReadTraceData(f)
+	if err != nil {
+		t.Fatalf("ReadTraceData; err = %v", err)
+	}
+
+	// The trace begins with a Sync event, which doesn't convert to an Event.
+	if have, want := data.StartNs, int64(4947251414080); have != want {
+		t.Errorf("StartNs; %d != %d", have, want)
+	}
+	if data.Events[0].Ts <= data.StartNs {
+		t.Errorf("first Event at %d, not after the start of the trace", data.Events[0].Ts)
+	}
+
+	// describe summarizes each of a goroutine's events with its type, its leaf
+	// function, and where its Link leads.
+	describe := func(g uint64, n int) []string {
+		var lines []string
+		for _, ev := range data.GoroutineEvents[g] {
+			if len(lines) == n {
+				break
+			}
+			line := ev.Type.String()
+			if len(ev.Stk) > 0 {
+				line += " " + ev.Stk[0].Function
+			}
+			if ev.Link != nil {
+				line += fmt.Sprintf(" -> %s g=%d", ev.Link.Type, ev.Link.G)
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+	check := func(g uint64, want ...string) {
+		t.Helper()
+		if have := describe(g, len(want)); !reflect.DeepEqual(have, want) {
+			t.Errorf("g%d events;\n%s\n!=\n%s", g, strings.Join(have, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	// The main goroutine was running when the trace began, so it gets a
+	// GoStart. It wakes g7 and creates g20 and g21, and g20 wakes it in turn.
+	// It sleeps, makes three short syscalls and a long one, and then creates
+	// the two CPU-bound goroutines.
+	check(1,
+		"GoStart",
+		"GoCreate runtime.traceStartReadCPU -> GoStart g=17",
+		"GoCreate runtime.(*traceAdvancerState).start -> GoStart g=18",
+		"GoCreate runtime/trace.(*traceMultiplexer).startLocked -> GoStart g=19",
+		"UserTaskCreate main.main",
+		"GoUnblock runtime.chansend1 -> GoStart g=7",
+		"GoCreate main.main -> GoStart g=20",
+		"GoCreate main.main -> GoStart g=21",
+		"GoBlockSend runtime.chansend1 -> GoUnblock g=20",
+		"GoStart",
+		"GoUnblock runtime.chanrecv1 -> GoStart g=21",
+		"GoSleep time.Sleep -> GoUnblock g=0",
+		"GoStart",
+		"GoSysCall syscall.openat",
+		"GoSysCall syscall.write",
+		"GoSysCall syscall.Close",
+		"GoSysCall syscall.Nanosleep",
+		"GoSysBlock -> GoSysExit g=1",
+		"GoSysExit",
+		"GoStart",
+		"GoCreate main.main -> GoStart g=22",
+		"GoCreate main.main -> GoStart g=23",
+		"GoBlockRecv runtime.chanrecv1 -> GoUnblock g=23",
+	)
+	if have, want := data.GoroutineEvents[1][0].Ts, int64(4947251492544); have != want {
+		t.Errorf("g1 GoStart at %d, expected %d", have, want)
+	}
+
+	// g7 was waiting when the trace began.
+	check(7,
+		"GoWaiting",
+		"GoStart",
+		"GoEnd",
+	)
+
+	// New goroutines start with the stack of their entry function.
+	check(20,
+		"GoStart main.relay",
+		"GoUnblock runtime.chanrecv1 -> GoStart g=1",
+		"GoUnblock runtime.chansend1 -> GoStart g=21",
+		"GoEnd",
+	)
+	check(21,
+		"GoStart main.relay",
+		"GoBlockRecv runtime.chanrecv1 -> GoUnblock g=20",
+		"GoStart",
+		"GoBlockSend runtime.chansend1 -> GoUnblock g=1",
+		"GoStart",
+		"GoEnd",
+	)
+
+	// The scheduler preempted one of the CPU-bound goroutines.
+	check(22,
+		"GoStart main.hog",
+		"GoPreempt time.Since",
+		"GoStart",
+		"GoUnblock runtime.chansend1 -> GoStart g=1",
+		"GoEnd",
+	)
+}
@@ -1,21 +1,163 @@
 package pattern
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/rhysh/go-tracing-toolbox/internal"
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 )
 
-func TrackAll(evs []*trace.Event) []*internal.Region {
+// defaultSpecs holds the parsed built-in patterns, which the Track functions
+// share and do not modify.
+var defaultSpecs = sync.OnceValue(DefaultSpecs)
+
+// TrackAll finds all of the Regions that the built-in patterns describe, and
+// those that the program annotated for itself.
+func TrackAll(evs []*internal.Event) []*internal.Region {
+	regions := findSpecs(evs, defaultSpecs())
+	regions = append(regions, TrackUserRegions(evs)...)
+	return regions
+}
+
+// trackKinds finds the Regions of the named Kinds, using the built-in patterns
+// for those Kinds and for any that they are Between.
+func trackKinds(evs []*internal.Event, kinds ...string) []*internal.Region {
+	want := make(map[string]bool)
+	for _, kind := range kinds {
+		want[kind] = true
+	}
+	all := defaultSpecs()
+	need := make(map[string]bool)
+	for i := len(all) - 1; i >= 0; i-- {
+		spec := all[i]
+		if want[spec.Kind] || need[spec.Kind] {
+			need[spec.Kind] = true
+			if spec.isBetween() {
+				need[spec.Between[0]] = true
+				need[spec.Between[1]] = true
+			}
+		}
+	}
+	var specs []*Spec
+	for _, spec := range all {
+		if need[spec.Kind] {
+			specs = append(specs, spec)
+		}
+	}
+	for _, kind := range kinds {
+		if !need[kind] {
+			panic(fmt.Sprintf("default.patterns has no %q pattern", kind))
+		}
+	}
+
 	var regions []*internal.Region
-	for _, fn := range []func([]*trace.Event) []*internal.Region{
-		TrackHTTP1Writer,
-		TrackHTTP1Reader,
-		TrackHTTPClient,
-		TrackHTTP1Dialer,
-		TrackHTTP1DNS,
-		TrackHTTP1Server,
-	} {
-		regions = append(regions, fn(evs)...)
+	for _, reg := range findSpecs(evs, specs) {
+		if want[reg.Kind] {
+			regions = append(regions, reg)
+		}
 	}
 	return regions
 }
+
+// TrackHTTP1Writer finds the "client/http_write" Regions, where an HTTP/1.x
+// connection writes an outbound request.
+func TrackHTTP1Writer(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http_write")
+}
+
+// TrackHTTP1Reader finds the "client/http_read" Regions, where an HTTP/1.x
+// connection reads an inbound response.
+func TrackHTTP1Reader(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http_read")
+}
+
+// TrackHTTPClient finds the "client/http_roundtrip" Regions, where a goroutine
+// makes an outbound HTTP request.
+func TrackHTTPClient(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http_roundtrip")
+}
+
+// TrackHTTP1Dialer finds the "client/http_dial" Regions, where an HTTP/1.x
+// Transport dials a new connection.
+func TrackHTTP1Dialer(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http_dial")
+}
+
+// TrackHTTP1DNS finds the "client/http_dns" Regions, where a DNS lookup runs
+// for a new HTTP/1.x connection.
+func TrackHTTP1DNS(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http_dns")
+}
+
+// TrackHTTP1Server finds the Regions where an HTTP/1.x server connection reads
+// a request's headers ("server/http_read"), handles the request
+// ("server/http"), and finishes its response ("server/http_write").
+func TrackHTTP1Server(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "server/http_read", "server/http_write", "server/http")
+}
+
+// TrackHTTP2Writer finds the "client/http2_write" Regions, where an outbound
+// HTTP/2 stream's goroutine writes its request.
+func TrackHTTP2Writer(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http2_write")
+}
+
+// TrackHTTP2Reader finds the "client/http2_read" Regions, where an HTTP/2
+// client connection processes a frame it read from the network.
+func TrackHTTP2Reader(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http2_read")
+}
+
+// TrackHTTP2Client finds the "client/http2_roundtrip" Regions, where a
+// goroutine makes an outbound HTTP/2 request.
+func TrackHTTP2Client(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/http2_roundtrip")
+}
+
+// TrackHTTP2Server finds the Regions where an HTTP/2 server connection
+// processes a frame it read ("server/http2_read"), handles a request
+// ("server/http2"), and schedules frames to write ("server/http2_write").
+func TrackHTTP2Server(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "server/http2_read", "server/http2", "server/http2_write")
+}
+
+// TrackGRPCWriter finds the "client/grpc_write" Regions, where a gRPC client
+// connection's loopyWriter goroutine writes frames to the network.
+func TrackGRPCWriter(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/grpc_write")
+}
+
+// TrackGRPCReader finds the "client/grpc_read" Regions, where a gRPC client
+// connection processes a frame it read from the network.
+func TrackGRPCReader(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/grpc_read")
+}
+
+// TrackGRPCClient finds the "client/grpc" Regions, where a goroutine makes an
+// outbound gRPC request.
+func TrackGRPCClient(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/grpc")
+}
+
+// TrackGRPCServer finds the Regions where a gRPC server connection processes a
+// frame it read ("server/grpc_read"), handles a request ("server/grpc"), and
+// writes frames to the network ("server/grpc_write").
+func TrackGRPCServer(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "server/grpc_read", "server/grpc", "server/grpc_write")
+}
+
+// TrackSQL finds the Regions where a goroutine uses a database/sql.DB.
+//
+// The "client/sql_conn" Regions are where it obtains a connection from the
+// DB's pool, which can include waiting for another goroutine to return one.
+// The other Regions are for the calls that make up most database work: running
+// a query or statement ("client/sql_query"), reading the rows of a query's
+// result ("client/sql_rows"), and beginning ("client/sql_begin") and committing
+// or rolling back ("client/sql_commit") a transaction. Those calls usually
+// include a "client/sql_conn" Region of their own.
+//
+// A call that finds a free connection and doesn't block on the network
+// leaves no events, so has no Region.
+func TrackSQL(evs []*internal.Event) []*internal.Region {
+	return trackKinds(evs, "client/sql_conn", "client/sql_query", "client/sql_rows", "client/sql_begin", "client/sql_commit")
+}
@@ -1,6 +1,7 @@
 package pattern_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/rhysh/go-tracing-toolbox/internal"
@@ -108,6 +109,274 @@ func TestHTTP1ServerRegions(t *testing.T) {
 	})
 }
 
+func TestHTTP2Regions(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/http2_proxy")
+	t.Run("server/http2", func(t *testing.T) {
+		regions := testhelp.FindAll(data, pattern.TrackHTTP2Server)
+		checkRegions(t, regions, "server/http2",
+			50, 1122682216832, 1122684557440,
+			52, 1122682299968, 1122684490624,
+			59, 1122687963776, 1122690542400,
+			61, 1122688060736, 1122690435968,
+			68, 1122693987328, 1122696525312,
+			70, 1122694084480, 1122696403200,
+			77, 1122699887104, 1122702553344,
+			79, 1122699954944, 1122702424448,
+			86, 1122705940096, 1122708466944,
+			88, 1122706025024, 1122708359424,
+		)
+		for _, reg := range regions {
+			switch reg.Kind {
+			case "server/http2_read":
+				if !reg.Shared() || !reg.Root() {
+					t.Errorf("%q region should be Shared and Root", reg.Kind)
+				}
+			case "server/http2":
+				if reg.Shared() || !reg.Root() {
+					t.Errorf("%q region should be Root and not Shared", reg.Kind)
+				}
+			}
+		}
+	})
+	t.Run("client/http2_roundtrip", func(t *testing.T) {
+		checkRegions(t, testhelp.FindAll(data, pattern.TrackHTTP2Client), "client/http2_roundtrip",
+			48, 1122682137600, 1122684569792,
+			50, 1122682264128, 1122684523072,
+			57, 1122687855744, 1122690578816,
+			59, 1122687989696, 1122690485376,
+			66, 1122693862784, 1122696543040,
+			68, 1122694016384, 1122696449408,
+			75, 1122699787328, 1122702582848,
+			77, 1122699914624, 1122702469568,
+			84, 1122705832320, 1122708485696,
+			86, 1122705978624, 1122708402048,
+		)
+	})
+	t.Run("client/http2_write", func(t *testing.T) {
+		checkRegions(t, testhelp.FindAll(data, pattern.TrackHTTP2Writer), "client/http2_write",
+			49, 1122682138944, 1122684568576,
+			51, 1122682265472, 1122684520768,
+			58, 1122687857984, 1122690576256,
+			60, 1122687990912, 1122690481600,
+			67, 1122693865344, 1122696540928,
+			69, 1122694017856, 1122696445120,
+			76, 1122699790208, 1122702580544,
+			78, 1122699916032, 1122702465792,
+			85, 1122705834560, 1122708483968,
+			87, 1122705979968, 1122708398016,
+		)
+	})
+	t.Run("client/http2_read", func(t *testing.T) {
+		checkRegions(t, testhelp.FindAll(data, pattern.TrackHTTP2Reader), "client/http2_read",
+			14, 1122684565888, 1122684567040,
+			14, 1122690555392, 1122690573056,
+			14, 1122696537472, 1122696539136,
+			14, 1122702567936, 1122702577024,
+			14, 1122708480064, 1122708481984,
+			26, 1122684515264, 1122684518400,
+			26, 1122690471488, 1122690477568,
+			26, 1122696434560, 1122696440128,
+			26, 1122702455872, 1122702461952,
+			26, 1122708388160, 1122708394176,
+		)
+	})
+}
+
+func TestGRPCRegions(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/grpc_proxy")
+	t.Run("server/grpc", func(t *testing.T) {
+		regions := testhelp.FindAll(data, pattern.TrackGRPCServer)
+		checkRegions(t, regions, "server/grpc",
+			41, 1552448186176, 1552449431040,
+			42, 1552449360384, 1552449398464,
+			44, 1552453703808, 1552454908928,
+			45, 1552454830912, 1552454877248,
+			47, 1552459316608, 1552460731584,
+			48, 1552460597696, 1552460649024,
+			50, 1552465135616, 1552466531136,
+			51, 1552466419904, 1552466452032,
+			53, 1552471022848, 1552472246656,
+			54, 1552472174656, 1552472205312,
+		)
+		count := make(map[string]int)
+		for _, reg := range regions {
+			count[reg.Kind]++
+			switch reg.Kind {
+			case "server/grpc_read":
+				if !reg.Shared() || !reg.Root() {
+					t.Errorf("%q region should be Shared and Root", reg.Kind)
+				}
+			case "server/grpc":
+				if reg.Shared() || !reg.Root() {
+					t.Errorf("%q region should be Root and not Shared", reg.Kind)
+				}
+			case "server/grpc_write":
+				if !reg.Shared() || reg.Root() {
+					t.Errorf("%q region should be Shared and not Root", reg.Kind)
+				}
+			}
+		}
+		if have, want := count["server/grpc_read"], 20; have != want {
+			t.Errorf("found %d server/grpc_read regions, expected %d", have, want)
+		}
+		if have, want := count["server/grpc_write"], 26; have != want {
+			t.Errorf("found %d server/grpc_write regions, expected %d", have, want)
+		}
+	})
+	t.Run("client/grpc", func(t *testing.T) {
+		checkRegions(t, testhelp.FindAll(data, pattern.TrackGRPCClient), "client/grpc",
+			40, 1552448110208, 1552449463424,
+			41, 1552449321536, 1552449422336,
+			43, 1552453642560, 1552454948864,
+			44, 1552454799680, 1552454900608,
+			46, 1552459183488, 1552460801920,
+			47, 1552460499520, 1552460689536,
+			49, 1552465028800, 1552466592640,
+			50, 1552466311168, 1552466501824,
+			52, 1552470925952, 1552472328640,
+			53, 1552472134272, 1552472234752,
+		)
+	})
+	t.Run("client/grpc_read", func(t *testing.T) {
+		regions := testhelp.FindAll(data, pattern.TrackGRPCReader)
+		if have, want := len(regions), 15; have != want {
+			t.Errorf("found %d client/grpc_read regions, expected %d", have, want)
+		}
+		for _, reg := range regions {
+			if !reg.Shared() {
+				t.Errorf("%q region should be Shared", reg.Kind)
+			}
+		}
+	})
+	t.Run("client/grpc_write", func(t *testing.T) {
+		regions := testhelp.FindAll(data, pattern.TrackGRPCWriter)
+		if have, want := len(regions), 25; have != want {
+			t.Errorf("found %d client/grpc_write regions, expected %d", have, want)
+		}
+		for _, reg := range regions {
+			if !reg.Shared() {
+				t.Errorf("%q region should be Shared", reg.Kind)
+			}
+		}
+	})
+}
+
+func TestSQLRegions(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/sql_pool")
+	regions := testhelp.FindAll(data, pattern.TrackSQL)
+	t.Run("client/sql_conn", func(t *testing.T) {
+		checkRegions(t, regions, "client/sql_conn",
+			10, 1830406429888, 1830410803712,
+			11, 1830402183616, 1830409703616,
+			12, 1830402189440, 1830406420608,
+			12, 1830410803264, 1830414079360,
+			13, 1830406420160, 1830406430208,
+		)
+	})
+	t.Run("client/sql_query", func(t *testing.T) {
+		checkRegions(t, regions, "client/sql_query",
+			10, 1830402174528, 1830403237824,
+			10, 1830411903104, 1830412978304,
+			11, 1830402183616, 1830410789056,
+			11, 1830415171136, 1830416254016,
+			12, 1830402189440, 1830407494400,
+			12, 1830415177216, 1830416247168,
+			13, 1830402123072, 1830403248768,
+			13, 1830407510016, 1830408595136,
+		)
+	})
+	t.Run("client/sql_rows", func(t *testing.T) {
+		checkRegions(t, regions, "client/sql_rows",
+			10, 1830403248064, 1830406428928,
+			11, 1830410792576, 1830414083776,
+			12, 1830407498752, 1830410799360,
+			13, 1830403249856, 1830406415616,
+		)
+	})
+	t.Run("client/sql_begin", func(t *testing.T) {
+		checkRegions(t, regions, "client/sql_begin",
+			10, 1830406429888, 1830411900224,
+			11, 1830414088576, 1830415168768,
+			12, 1830410803264, 1830415176384,
+			13, 1830406420160, 1830407506048,
+		)
+	})
+	t.Run("client/sql_commit", func(t *testing.T) {
+		checkRegions(t, regions, "client/sql_commit",
+			10, 1830412984192, 1830414077248,
+			11, 1830416255232, 1830417332160,
+			12, 1830416250304, 1830417335296,
+			13, 1830408602624, 1830409701312,
+		)
+	})
+}
+
+func TestUserRegions(t *testing.T) {
+	data := testhelp.Load(t, "../../testdata/go1.27.1/user_task")
+	regions := testhelp.FindAll(data, pattern.TrackUserRegions)
+	t.Run("request", func(t *testing.T) {
+		checkRegions(t, regions, "request",
+			12, 2133328433408, 2133332754112,
+			13, 2133328435648, 2133335937984,
+			14, 2133328404032, 2133333786560,
+		)
+	})
+	t.Run("parse", func(t *testing.T) {
+		checkRegions(t, regions, "parse",
+			12, 2133328433984, 2133329516032,
+			13, 2133328436224, 2133329520704,
+			14, 2133328405632, 2133329508864,
+		)
+	})
+	t.Run("work", func(t *testing.T) {
+		checkRegions(t, regions, "work",
+			6, 2133329514432, 2133330588608,
+			6, 2133330591872, 2133331688384,
+			7, 2133329519360, 2133330595776,
+		)
+	})
+	t.Run("flush", func(t *testing.T) {
+		checkRegions(t, regions, "flush",
+			8, 2133331682816, 2133332746816,
+			8, 2133332749888, 2133333782080,
+			8, 2133334857024, 2133335933952,
+		)
+	})
+	t.Run("write", func(t *testing.T) {
+		checkRegions(t, regions, "write",
+			8, 2133331684672, 2133332746368,
+			8, 2133332751040, 2133333781696,
+			8, 2133334858304, 2133335933504,
+		)
+	})
+	t.Run("tasks", func(t *testing.T) {
+		// Each "flush" task is a child of a "request" task, and the regions
+		// do work for the task in which they run.
+		tasks := make(map[uint64]*internal.Region)
+		for _, reg := range regions {
+			if reg.Task != 0 {
+				tasks[reg.Task] = reg
+			}
+		}
+		for _, reg := range regions {
+			parent := tasks[reg.ParentTask]
+			var want string
+			switch reg.Kind {
+			case "request":
+				continue
+			case "parse", "work", "flush":
+				want = "request"
+			case "write":
+				want = "flush"
+			}
+			if parent == nil || parent.Kind != want {
+				t.Errorf("%q region on g %d at %d has parent task %d, expected a %q task",
+					reg.Kind, reg.Events[0].G, reg.Events[0].Ts, reg.ParentTask, want)
+			}
+		}
+	})
+}
+
 func TestGCAssist(t *testing.T) {
 	data := testhelp.Load(t, "../../testdata/f2f5b4bd_go1.18.7/gc_assist")
 	t.Run("", func(t *testing.T) {
@@ -130,3 +399,71 @@ func TestGCAssist(t *testing.T) {
 		)
 	})
 }
+
+func TestParseSpecs(t *testing.T) {
+	goodcase := func(v string, kinds ...string) func(t *testing.T) {
+		return func(t *testing.T) {
+			specs, err := pattern.ParseSpecs(strings.NewReader(v))
+			if err != nil {
+				t.Fatalf("ParseSpecs; err = %v", err)
+			}
+			var have []string
+			for _, spec := range specs {
+				have = append(have, spec.Kind)
+			}
+			if strings.Join(have, " ") != strings.Join(kinds, " ") {
+				t.Errorf("ParseSpecs kinds = %q, expected %q", have, kinds)
+			}
+		}
+	}
+
+	badcase := func(v string) func(t *testing.T) {
+		return func(t *testing.T) {
+			_, err := pattern.ParseSpecs(strings.NewReader(v))
+			if err == nil {
+				t.Fatalf("ParseSpecs(%q); err = nil", v)
+			}
+		}
+	}
+
+	t.Run("", goodcase(""))
+	t.Run("", goodcase("# just a comment\n"))
+	t.Run("", goodcase("pattern a\nactivate Any \"**\"\n", "a"))
+	t.Run("", goodcase("pattern a\nshared\nroot\nallow-single\nflush-at-end\nreactivate\n"+
+		"activate GoStart \"**\"\nkeepalive !GoEnd \"**\"\nkeepalive Any\ncritical Any \".*\" \"**\"\n"+
+		"pattern b\nactivate Any \"**\"\n"+
+		"pattern c\nbetween a b\n", "a", "b", "c"))
+	t.Run("", goodcase("define pkg (?:net/http|golang.org/x/net/http)\ndefine fn ${pkg}...Transport..RoundTrip$\n"+
+		"pattern a\nactivate Any \"**\" \"^${fn}\" \"**\"\n", "a"))
+
+	t.Run("define", func(t *testing.T) {
+		specs, err := pattern.ParseSpecs(strings.NewReader("define pkg (?:net/http|golang.org/x/net/http)\n" +
+			"pattern a\nactivate !Any \"**\" \"^${pkg}...Transport..RoundTrip$\" \"**\"\n"))
+		if err != nil {
+			t.Fatalf("ParseSpecs; err = %v", err)
+		}
+		have := specs[0].Activate[0].String()
+		want := `!Any "**" "^(?:net/http|golang.org/x/net/http)...Transport..RoundTrip$" "**"`
+		if have != want {
+			t.Errorf("rule = %s, expected %s", have, want)
+		}
+	})
+
+	t.Run("", badcase("activate Any \"**\"\n"))
+	t.Run("", badcase("pattern\n"))
+	t.Run("", badcase("pattern a\n"))
+	t.Run("", badcase("pattern a\nkeepalive Any\n"))
+	t.Run("", badcase("pattern a\nactivate Foo\n"))
+	t.Run("", badcase("pattern a\nactivate Any \"[\"\n"))
+	t.Run("", badcase("pattern a\nactivate Any\nshared yes\n"))
+	t.Run("", badcase("pattern a\nactivate Any \"^${pkg}\"\n"))
+	t.Run("", badcase("pattern a\nactivate Any \"^${pkg}\"\ndefine pkg net/http\n"))
+	t.Run("", badcase("define pkg\n"))
+	t.Run("", badcase("define a.b net/http\n"))
+	t.Run("", badcase("define pkg net/http\ndefine pkg golang.org/x/net/http\n"))
+	t.Run("", badcase("pattern a\nactivate Any\nunknown\n"))
+	t.Run("", badcase("pattern a\nactivate Any\npattern a\nactivate Any\n"))
+	t.Run("", badcase("pattern a\nbetween a b\n"))
+	t.Run("", badcase("pattern a\nactivate Any\npattern b\nbetween a\n"))
+	t.Run("", badcase("pattern a\nactivate Any\npattern b\nbetween a a\nactivate Any\n"))
+}
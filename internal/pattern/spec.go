@@ -0,0 +1,388 @@
+package pattern
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/rhysh/go-tracing-toolbox/internal"
+)
+
+// A Spec describes how to find one Kind of Region, so the Region-finding
+// patterns can be defined at run time rather than as Go code.
+//
+// Most Specs describe the Activate, Keepalive, and Critical functions of an
+// internal.GeneralTracker as lists of Rules. Others describe a Region as the
+// space Between two other Kinds of Region on the same goroutine, as with the
+// "server/http" Regions that TrackHTTP1Server finds.
+type Spec struct {
+	Kind string
+
+	// Shared sets internal.RegionFlagShared on the Regions.
+	Shared bool
+	// Root sets internal.RegionFlagRoot on the Regions.
+	Root bool
+
+	AllowSingle bool
+	FlushAtEnd  bool
+	Reactivate  bool
+
+	Activate  []Rule
+	Keepalive []Rule
+	Critical  []Rule
+
+	// Between holds the Kinds of Region that begin and end the negative
+	// space that this Spec describes.
+	Between [2]string
+}
+
+// A Rule matches an event by its type and call stack. When the first Rule in a
+// list to match an event is a Negate rule, the list as a whole does not match
+// that event.
+type Rule struct {
+	Negate bool
+	Match  internal.StackFlag
+}
+
+func (r Rule) String() string {
+	if r.Negate {
+		return "!" + r.Match.String()
+	}
+	return r.Match.String()
+}
+
+// ruleFunc converts a list of Rules into a predicate for a GeneralTracker. It
+// returns nil for an empty list, which the GeneralTracker treats specially
+// for its Critical function.
+func ruleFunc(rules []Rule) func(ev *internal.Event) bool {
+	if len(rules) == 0 {
+		return nil
+	}
+	return func(ev *internal.Event) bool {
+		for _, r := range rules {
+			if r.Match.Matches(ev) {
+				return !r.Negate
+			}
+		}
+		return false
+	}
+}
+
+// Tracker returns a new GeneralTracker with the Spec's functions and options.
+// The caller is responsible for setting its Flush function.
+func (s *Spec) Tracker() *internal.GeneralTracker {
+	return &internal.GeneralTracker{
+		Activate:    ruleFunc(s.Activate),
+		Keepalive:   ruleFunc(s.Keepalive),
+		Critical:    ruleFunc(s.Critical),
+		Reactivate:  s.Reactivate,
+		AllowSingle: s.AllowSingle,
+		FlushAtEnd:  s.FlushAtEnd,
+	}
+}
+
+func (s *Spec) isBetween() bool { return s.Between != [2]string{} }
+
+// TrackSpecs returns a function that finds the Regions that the Specs
+// describe in a single goroutine's events, in the style of TrackAll. As with
+// TrackAll, that includes the Regions that the program annotated for itself,
+// from TrackUserRegions.
+func TrackSpecs(specs []*Spec) func(evs []*internal.Event) []*internal.Region {
+	return func(evs []*internal.Event) []*internal.Region {
+		regions := findSpecs(evs, specs)
+		regions = append(regions, TrackUserRegions(evs)...)
+		return regions
+	}
+}
+
+// findSpecs finds the Regions that the Specs describe, in the order of the
+// Specs.
+func findSpecs(evs []*internal.Event, specs []*Spec) []*internal.Region {
+	var regions []*internal.Region
+	byKind := make(map[string][]*internal.Region)
+	for _, spec := range specs {
+		var found []*internal.Region
+		if spec.isBetween() {
+			found = negativeSpace(evs, byKind[spec.Between[0]], byKind[spec.Between[1]])
+		} else {
+			track := spec.Tracker()
+			track.Flush = func(evs []*internal.Event) {
+				found = append(found, &internal.Region{Events: evs})
+			}
+			track.Process(evs)
+		}
+		for _, reg := range found {
+			reg.Kind = spec.Kind
+			if spec.Shared {
+				reg.Flags |= internal.RegionFlagShared
+			}
+			if spec.Root {
+				reg.Flags |= internal.RegionFlagRoot
+			}
+		}
+		byKind[spec.Kind] = append(byKind[spec.Kind], found...)
+		regions = append(regions, found...)
+	}
+	return regions
+}
+
+//go:embed default.patterns
+var defaultPatterns string
+
+// DefaultSpecs returns the built-in Region-finding patterns, which TrackAll
+// and the other Track functions use.
+func DefaultSpecs() []*Spec {
+	specs, err := ParseSpecs(strings.NewReader(defaultPatterns))
+	if err != nil {
+		panic(fmt.Errorf("default.patterns: %w", err))
+	}
+	return specs
+}
+
+// ParseSpecs reads a list of Region-finding patterns. The format is
+// line-oriented, with "#" starting a comment line. Each pattern begins with a
+// "pattern" line naming the Kind of Region it finds, followed by lines that
+// set its options and list its rules:
+//
+//	pattern client/http_roundtrip
+//	activate  Any "**" "^net/http...Transport..RoundTrip$" "**"
+//	keepalive Any
+//	keepalive Any "**" "^net/http...Transport..RoundTrip$" "**"
+//	critical  GoStart "**"
+//	critical  Any "**" "^net/http...Transport..RoundTrip$" "**"
+//
+// The options are "shared", "root", "allow-single", "flush-at-end", and
+// "reactivate". Each rule is an event matcher in the format of StackFlag,
+// optionally prefixed with "!" to make a match count as a rejection. As with
+// match2.HasStackRe, a rule with no stack specs matches only events that have
+// no call stack; use "**" to match any stack.
+//
+// A "between <start-kind> <end-kind>" line, in place of the rules, describes
+// Regions that span the gap from the end of one Region to the start of the
+// next on the same goroutine. The Kinds it names must be defined earlier in the
+// list.
+//
+// A "define <name> <text>" line, which may appear anywhere in the file, lets
+// the rules that follow it write "${name}" in place of the text. That keeps a
+// long expression, such as one that matches several copies of a package, in a
+// single place:
+//
+//	define http (?:net/http|golang.org/x/net/http)
+//	activate Any "**" "^${http}...Transport..RoundTrip$" "**"
+func ParseSpecs(r io.Reader) ([]*Spec, error) {
+	var (
+		specs   []*Spec
+		spec    *Spec
+		known   = make(map[string]bool)
+		defines = make(map[string]string)
+	)
+
+	expand := func(s string) (string, error) {
+		var err error
+		s = defineRef.ReplaceAllStringFunc(s, func(ref string) string {
+			name := defineRef.FindStringSubmatch(ref)[1]
+			text, ok := defines[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("undefined name %q", name)
+			}
+			return text
+		})
+		return s, err
+	}
+
+	finish := func() error {
+		if spec == nil {
+			return nil
+		}
+		hasRules := len(spec.Activate) > 0 || len(spec.Keepalive) > 0 || len(spec.Critical) > 0
+		switch {
+		case spec.isBetween() && hasRules:
+			return fmt.Errorf("pattern %q has both rules and a between line", spec.Kind)
+		case !spec.isBetween() && len(spec.Activate) == 0:
+			return fmt.Errorf("pattern %q has no activate rules", spec.Kind)
+		}
+		known[spec.Kind] = true
+		specs = append(specs, spec)
+		return nil
+	}
+
+	sc := bufio.NewScanner(r)
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		err := func() error {
+			if directive == "define" {
+				name, text, _ := strings.Cut(arg, " ")
+				text = strings.TrimSpace(text)
+				if !defineName.MatchString(name) || text == "" {
+					return fmt.Errorf("define line needs a name and its text")
+				}
+				if _, ok := defines[name]; ok {
+					return fmt.Errorf("duplicate definition of %q", name)
+				}
+				text, err := expand(text)
+				if err != nil {
+					return err
+				}
+				defines[name] = text
+				return nil
+			}
+			if directive == "pattern" {
+				if err := finish(); err != nil {
+					return err
+				}
+				if arg == "" || strings.ContainsAny(arg, " \t") {
+					return fmt.Errorf("invalid pattern kind %q", arg)
+				}
+				if known[arg] {
+					return fmt.Errorf("duplicate pattern kind %q", arg)
+				}
+				spec = &Spec{Kind: arg}
+				return nil
+			}
+			if spec == nil {
+				return fmt.Errorf("%q line before first pattern line", directive)
+			}
+
+			flags := map[string]*bool{
+				"shared":       &spec.Shared,
+				"root":         &spec.Root,
+				"allow-single": &spec.AllowSingle,
+				"flush-at-end": &spec.FlushAtEnd,
+				"reactivate":   &spec.Reactivate,
+			}
+			rules := map[string]*[]Rule{
+				"activate":  &spec.Activate,
+				"keepalive": &spec.Keepalive,
+				"critical":  &spec.Critical,
+			}
+
+			if p, ok := flags[directive]; ok {
+				if arg != "" {
+					return fmt.Errorf("option %q takes no arguments", directive)
+				}
+				*p = true
+				return nil
+			}
+			if p, ok := rules[directive]; ok {
+				var rule Rule
+				if strings.HasPrefix(arg, "!") {
+					rule.Negate = true
+					arg = arg[1:]
+				}
+				arg, err := expand(arg)
+				if err != nil {
+					return err
+				}
+				if err := rule.Match.Set(arg); err != nil {
+					return err
+				}
+				*p = append(*p, rule)
+				return nil
+			}
+			if directive == "between" {
+				kinds := strings.Fields(arg)
+				if len(kinds) != 2 {
+					return fmt.Errorf("between line needs two kinds, found %d", len(kinds))
+				}
+				for _, kind := range kinds {
+					if !known[kind] {
+						return fmt.Errorf("between line names unknown pattern kind %q", kind)
+					}
+				}
+				spec.Between = [2]string{kinds[0], kinds[1]}
+				return nil
+			}
+			return fmt.Errorf("unknown directive %q", directive)
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+var (
+	defineName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	defineRef  = regexp.MustCompile(`\$\{([^{}]*)\}`)
+)
+
+// negativeSpace creates Regions from the negative (empty) space between two
+// lists of Regions. It assumes that the elements within each list do not
+// overlap, though they may share a single event at their boundaries. It assumes
+// that the input lists are sorted by start timestamp.
+//
+// For each Region it returns, the first event will appear as the last event in
+// a Region from the starts list, and the last event will appear as the first
+// event in the ends list. None of the other events in the Region will appear in
+// any input Region.
+func negativeSpace(evs []*internal.Event, starts, ends []*internal.Region) []*internal.Region {
+	var regions []*internal.Region
+	var start *internal.Region
+	var queue []*internal.Event
+	for _, ev := range evs {
+		queue = append(queue, ev)
+
+		for i := range starts {
+			sevs := starts[i].Events
+			sevN := sevs[len(sevs)-1]
+			if sevN == ev {
+				// starts[i] is the most recent before this event. Trim it from
+				// the list, and try to use its last event as the start of an
+				// output Region.
+				start, starts = starts[i], starts[i+1:]
+				queue = []*internal.Event{ev}
+				break
+			}
+			if sevN.Ts > ev.Ts {
+				break
+			}
+		}
+
+		for len(ends) > 0 {
+			eevs := ends[0].Events
+			eev0 := eevs[0]
+			eevN := eevs[len(eevs)-1]
+
+			if eevN.Ts < ev.Ts {
+				// The end Region is fully older than the current event; discard
+				// it.
+				ends = ends[1:]
+				continue
+			}
+			if eev0.Ts < ev.Ts {
+				// The end Region overlaps with the current event; discard it
+				// and reset.
+				ends = ends[1:]
+				start, queue = nil, nil
+				continue
+			}
+			if eev0 == ev {
+				// The end Region starts at this event. Keep it.
+				if start != nil {
+					regions = append(regions, &internal.Region{Events: queue})
+					start = nil
+				}
+				queue = nil
+			}
+			break
+		}
+	}
+
+	return regions
+}
@@ -0,0 +1,96 @@
+package pattern
+
+import (
+	"sort"
+
+	"github.com/rhysh/go-tracing-toolbox/internal"
+)
+
+// TrackUserRegions finds the Regions that the program described for itself
+// with the runtime/trace package's user tasks and regions.
+//
+// Each user region becomes a Region, from its beginning to its end, with the
+// region's type as its Kind. A user region that is still open when the
+// goroutine's events run out extends to the goroutine's final event; one that
+// began before the trace did is not reported.
+//
+// Each user task becomes a Region too, with the task's type as its Kind. When
+// the task ends on the goroutine that created it, the Region runs from the
+// creation to the end. Otherwise it consists of only the creation event, and
+// serves as the point where the task's work on other goroutines attaches. The
+// task ids become the Regions' Task and ParentTask fields, which the
+// RegionConnector uses to link work on a task to the task itself.
+func TrackUserRegions(evs []*internal.Event) []*internal.Region {
+	var regions []*internal.Region
+
+	tasks := make(map[uint64]int)
+	var open []int
+	for i, ev := range evs {
+		switch ev.Type {
+		case internal.EvUserTaskCreate:
+			tasks[ev.Args[0]] = i
+		case internal.EvUserTaskEnd:
+			j, ok := tasks[ev.Args[0]]
+			if !ok {
+				continue
+			}
+			delete(tasks, ev.Args[0])
+			regions = append(regions, userTaskRegion(evs[j:i+1]))
+		case internal.EvUserRegion:
+			if ev.Args[1] == 0 {
+				open = append(open, i)
+				continue
+			}
+			// User regions on a goroutine must nest. Find the matching
+			// beginning, and consider any regions inside it to have ended too.
+			for k := len(open) - 1; k >= 0; k-- {
+				start := evs[open[k]]
+				if start.Args[0] != ev.Args[0] || userName(start) != userName(ev) {
+					continue
+				}
+				for _, j := range open[k:] {
+					regions = append(regions, userRegion(evs[j:i+1]))
+				}
+				open = open[:k]
+				break
+			}
+		}
+	}
+	for _, j := range open {
+		regions = append(regions, userRegion(evs[j:]))
+	}
+	var unended []int
+	for _, j := range tasks {
+		unended = append(unended, j)
+	}
+	sort.Ints(unended)
+	for _, j := range unended {
+		regions = append(regions, userTaskRegion(evs[j:j+1]))
+	}
+
+	return regions
+}
+
+func userRegion(evs []*internal.Event) *internal.Region {
+	return &internal.Region{
+		Kind:       userName(evs[0]),
+		Events:     evs,
+		ParentTask: evs[0].Args[0],
+	}
+}
+
+func userTaskRegion(evs []*internal.Event) *internal.Region {
+	return &internal.Region{
+		Kind:       userName(evs[0]),
+		Events:     evs,
+		Task:       evs[0].Args[0],
+		ParentTask: evs[0].Args[1],
+	}
+}
+
+func userName(ev *internal.Event) string {
+	if len(ev.SArgs) == 0 {
+		return ""
+	}
+	return ev.SArgs[0]
+}
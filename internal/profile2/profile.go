@@ -0,0 +1,79 @@
+// Package profile2 builds pprof profiles from the call stacks in v2 execution
+// traces.
+package profile2
+
+import (
+	"github.com/google/pprof/profile"
+	"golang.org/x/exp/trace"
+)
+
+// A Builder collects the functions and locations of the samples in Profile.
+// The caller sets Profile's sample types and adds its samples, using Location
+// to describe each of their stack frames.
+type Builder struct {
+	Profile   profile.Profile
+	mapping   profile.Mapping
+	functions map[[2]string]*profile.Function
+	locations map[uint64]*profile.Location
+}
+
+func (b *Builder) init() {
+	if b.functions != nil {
+		return
+	}
+	b.mapping.ID = 1
+	b.mapping.HasFilenames = true
+	b.mapping.HasFunctions = true
+	b.mapping.HasLineNumbers = true
+	b.Profile.Mapping = append(b.Profile.Mapping, &b.mapping)
+	b.functions = make(map[[2]string]*profile.Function)
+	b.locations = make(map[uint64]*profile.Location)
+}
+
+func (b *Builder) function(f trace.StackFrame) *profile.Function {
+	b.init()
+	key := [2]string{f.Func, f.File}
+	fn, ok := b.functions[key]
+	if !ok {
+		fn = &profile.Function{
+			Name:       f.Func,
+			SystemName: f.Func,
+			Filename:   f.File,
+			ID:         uint64(len(b.functions) + 1),
+		}
+		b.functions[key] = fn
+		b.Profile.Function = append(b.Profile.Function, fn)
+	}
+	return fn
+}
+
+// Location returns the profile's Location for the stack frame, adding it if
+// it's new.
+func (b *Builder) Location(f trace.StackFrame) *profile.Location {
+	b.init()
+	l, ok := b.locations[f.PC]
+	if !ok {
+		l = &profile.Location{
+			Address: f.PC,
+			Line: []profile.Line{{
+				Function: b.function(f),
+				Line:     int64(f.Line),
+			}},
+			Mapping: &b.mapping,
+			ID:      uint64(len(b.locations) + 1),
+		}
+		b.locations[f.PC] = l
+		b.Profile.Location = append(b.Profile.Location, l)
+	}
+	return l
+}
+
+// Stack returns the profile's Locations for the frames of stk, starting with
+// the leaf.
+func (b *Builder) Stack(stk trace.Stack) []*profile.Location {
+	var locs []*profile.Location
+	for f := range stk.Frames() {
+		locs = append(locs, b.Location(f))
+	}
+	return locs
+}
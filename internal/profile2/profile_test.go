@@ -0,0 +1,50 @@
+package profile2_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/rhysh/go-tracing-toolbox/internal/profile2"
+	"golang.org/x/exp/trace"
+)
+
+func TestBuilder(t *testing.T) {
+	var b profile2.Builder
+	b.Profile.SampleType = []*profile.ValueType{{Type: "events", Unit: "count"}}
+
+	frames := []trace.StackFrame{
+		{PC: 0x1010, Func: "main.leaf", File: "main.go", Line: 10},
+		{PC: 0x1020, Func: "main.leaf", File: "main.go", Line: 12},
+		{PC: 0x2000, Func: "main.main", File: "main.go", Line: 20},
+	}
+	for range 2 {
+		var locs []*profile.Location
+		for _, f := range frames {
+			locs = append(locs, b.Location(f))
+		}
+		b.Profile.Sample = append(b.Profile.Sample, &profile.Sample{Location: locs, Value: []int64{1}})
+	}
+
+	if have, want := len(b.Profile.Location), 3; have != want {
+		t.Errorf("len(Location) = %d, want %d", have, want)
+	}
+	if have, want := len(b.Profile.Function), 2; have != want {
+		t.Errorf("len(Function) = %d, want %d", have, want)
+	}
+	if err := b.Profile.CheckValid(); err != nil {
+		t.Fatalf("CheckValid: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Profile.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	prof, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if have, want := prof.Sample[0].Location[0].Line[0].Function.Name, "main.leaf"; have != want {
+		t.Errorf("leaf function = %q, want %q", have, want)
+	}
+}
@@ -0,0 +1,214 @@
+// Package report describes the distribution of latency across many trees of
+// Spans, such as all of the requests found in a collection of execution
+// traces.
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+)
+
+// Quantiles lists the points of each distribution that a Report describes.
+var Quantiles = []float64{0.50, 0.90, 0.99, 0.999}
+
+// A Report collects TreeSummaries, grouped by the Kind of their root Span.
+type Report struct {
+	top   int
+	kinds map[string]*kind
+}
+
+type kind struct {
+	count   int
+	length  []int64
+	run     []int64
+	wait    map[string][]int64
+	slowest []*Example
+}
+
+// An Example identifies a single tree of Spans, with enough detail to find it
+// again in the execution trace.
+type Example struct {
+	Trace        string
+	TraceStartNs int64
+	G            uint64
+	StartNs      int64
+	LengthNs     int64
+}
+
+// New returns an empty Report that will remember the top slowest examples of
+// each Kind.
+func New(top int) *Report {
+	return &Report{top: top, kinds: make(map[string]*kind)}
+}
+
+// Add includes the TreeSummary in the Report.
+func (r *Report) Add(summary *cluster.TreeSummary) {
+	root := summary.Root
+	k, ok := r.kinds[root.Kind]
+	if !ok {
+		k = &kind{wait: make(map[string][]int64)}
+		r.kinds[root.Kind] = k
+	}
+
+	// Each wait reason's distribution includes the trees that didn't wait
+	// for that reason at all.
+	for reason := range summary.FlatWaitNs {
+		if _, ok := k.wait[reason]; !ok {
+			k.wait[reason] = make([]int64, k.count)
+		}
+	}
+	for reason := range k.wait {
+		k.wait[reason] = append(k.wait[reason], summary.FlatWaitNs[reason])
+	}
+	k.count++
+	k.length = append(k.length, summary.LengthNs)
+	k.run = append(k.run, summary.FlatRunNs)
+
+	ex := &Example{
+		Trace:        summary.Trace,
+		TraceStartNs: summary.TraceStartNs,
+		G:            root.G,
+		StartNs:      root.StartNs,
+		LengthNs:     summary.LengthNs,
+	}
+	i := sort.Search(len(k.slowest), func(i int) bool { return k.slowest[i].LengthNs < ex.LengthNs })
+	if i < r.top {
+		k.slowest = append(k.slowest, nil)
+		copy(k.slowest[i+1:], k.slowest[i:])
+		k.slowest[i] = ex
+		if len(k.slowest) > r.top {
+			k.slowest = k.slowest[:r.top]
+		}
+	}
+}
+
+// A Distribution describes one measurement of the trees of a single Kind.
+type Distribution struct {
+	// Name is "length" for the trees' LengthNs, "run" for FlatRunNs, and
+	// "wait/" followed by the reason for each of FlatWaitNs.
+	Name string
+	// Values holds the distribution's value at each of Quantiles, in
+	// nanoseconds.
+	Values []int64
+	// Max is the largest value, in nanoseconds.
+	Max int64
+}
+
+// A Summary describes the trees of a single Kind.
+type Summary struct {
+	Kind          string
+	Count         int
+	Distributions []*Distribution
+	// Slowest lists the trees with the largest LengthNs, longest first.
+	Slowest []*Example
+}
+
+// Summaries describes each Kind in the Report, most common first.
+func (r *Report) Summaries() []*Summary {
+	var out []*Summary
+	for name, k := range r.kinds {
+		s := &Summary{Kind: name, Count: k.count, Slowest: k.slowest}
+		s.Distributions = append(s.Distributions, distribution("length", k.length))
+		s.Distributions = append(s.Distributions, distribution("run", k.run))
+		var reasons []string
+		for reason := range k.wait {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			s.Distributions = append(s.Distributions, distribution("wait/"+reason, k.wait[reason]))
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Kind < out[j].Kind
+	})
+	return out
+}
+
+func distribution(name string, vals []int64) *Distribution {
+	sorted := append([]int64(nil), vals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	d := &Distribution{Name: name}
+	for _, q := range Quantiles {
+		d.Values = append(d.Values, Quantile(sorted, q))
+	}
+	if len(sorted) > 0 {
+		d.Max = sorted[len(sorted)-1]
+	}
+	return d
+}
+
+// Quantile returns the value at quantile q of the sorted values, using the
+// nearest-rank method: the smallest value that is at least as large as q of
+// the values.
+func Quantile(sorted []int64, q float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return sorted[i]
+}
+
+// WriteText prints the Report as a table for each Kind, followed by the
+// slowest examples. Each example lists its execution trace file, goroutine,
+// and start time relative to the start of the trace (as "go tool trace"
+// shows it).
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	for i, s := range r.Summaries() {
+		if i > 0 {
+			fmt.Fprintf(tw, "\n")
+		}
+		fmt.Fprintf(tw, "%s: %d\n", s.Kind, s.Count)
+
+		fmt.Fprintf(tw, "\t")
+		for _, q := range Quantiles {
+			fmt.Fprintf(tw, "p%s\t", strconv.FormatFloat(q*100, 'g', 4, 64))
+		}
+		fmt.Fprintf(tw, "max\t\n")
+		for _, d := range s.Distributions {
+			fmt.Fprintf(tw, "%s\t", d.Name)
+			for _, v := range d.Values {
+				fmt.Fprintf(tw, "%s\t", formatNs(v))
+			}
+			fmt.Fprintf(tw, "%s\t\n", formatNs(d.Max))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		if len(s.Slowest) > 0 {
+			fmt.Fprintf(w, "slowest:\n")
+		}
+		for _, ex := range s.Slowest {
+			trace := ex.Trace
+			if trace == "" {
+				trace = "-"
+			}
+			fmt.Fprintf(w, "  %s g=%d at=%s %s\n",
+				formatNs(ex.LengthNs), ex.G, time.Duration(ex.StartNs-ex.TraceStartNs), trace)
+		}
+	}
+	return tw.Flush()
+}
+
+// formatNs rounds durations of a millisecond or longer to the microsecond.
+func formatNs(v int64) string {
+	d := time.Duration(v)
+	if d >= time.Millisecond {
+		d = d.Round(time.Microsecond)
+	}
+	return d.String()
+}
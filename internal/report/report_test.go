@@ -0,0 +1,103 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+)
+
+func TestQuantile(t *testing.T) {
+	vals := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, tt := range []struct {
+		vals []int64
+		q    float64
+		want int64
+	}{
+		{nil, 0.5, 0},
+		{[]int64{7}, 0.999, 7},
+		{vals, 0, 1},
+		{vals, 0.5, 5},
+		{vals, 0.51, 6},
+		{vals, 0.9, 9},
+		{vals, 0.99, 10},
+		{vals, 1, 10},
+	} {
+		if have := Quantile(tt.vals, tt.q); have != tt.want {
+			t.Errorf("Quantile(%v, %v); %d != %d", tt.vals, tt.q, have, tt.want)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := New(2)
+	add := func(kind string, g uint64, length, run int64, wait map[string]int64) {
+		r.Add(&cluster.TreeSummary{
+			LengthNs:     length,
+			FlatRunNs:    run,
+			FlatWaitNs:   wait,
+			Root:         &cluster.Span{Kind: kind, G: g, StartNs: 1000 + int64(g)},
+			Trace:        "trace-" + kind,
+			TraceStartNs: 1000,
+		})
+	}
+	add("server/http", 1, 100, 10, map[string]int64{"cpu": 5})
+	add("server/http", 2, 400, 40, map[string]int64{"net": 300})
+	add("server/http", 3, 200, 20, nil)
+	add("server/http", 4, 300, 30, map[string]int64{"cpu": 7})
+	add("client/http", 5, 50, 5, nil)
+
+	summaries := r.Summaries()
+	if have, want := len(summaries), 2; have != want {
+		t.Fatalf("found %d kinds, expected %d", have, want)
+	}
+	s := summaries[0]
+	if s.Kind != "server/http" || s.Count != 4 {
+		t.Errorf("first kind is %q with %d trees", s.Kind, s.Count)
+	}
+
+	dists := make(map[string]*Distribution)
+	var names []string
+	for _, d := range s.Distributions {
+		dists[d.Name] = d
+		names = append(names, d.Name)
+	}
+	if have, want := names, []string{"length", "run", "wait/cpu", "wait/net"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("distributions; %q != %q", have, want)
+	}
+	for name, want := range map[string][]int64{
+		"length":   {200, 400, 400, 400},
+		"run":      {20, 40, 40, 40},
+		"wait/cpu": {0, 7, 7, 7},
+		"wait/net": {0, 300, 300, 300},
+	} {
+		if have := dists[name].Values; !reflect.DeepEqual(have, want) {
+			t.Errorf("distribution %q; %d != %d", name, have, want)
+		}
+	}
+
+	var slowest []uint64
+	for _, ex := range s.Slowest {
+		slowest = append(slowest, ex.G)
+	}
+	if have, want := slowest, []uint64{2, 4}; !reflect.DeepEqual(have, want) {
+		t.Errorf("slowest goroutines; %d != %d", have, want)
+	}
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText; err = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"server/http: 4\n",
+		"p99.9",
+		"  400ns g=2 at=2ns trace-server/http\n",
+		"client/http: 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteText output does not include %q:\n%s", want, out)
+		}
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"testing"
 
 	"github.com/rhysh/go-tracing-toolbox/internal"
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 	"github.com/rhysh/go-tracing-toolbox/internal/exectext"
 )
 
@@ -64,7 +63,7 @@ func Load(t *testing.T, dir string) *internal.Data {
 	return data
 }
 
-func FindAll(data *internal.Data, fn func(evs []*trace.Event) []*internal.Region) []*internal.Region {
+func FindAll(data *internal.Data, fn func(evs []*internal.Event) []*internal.Region) []*internal.Region {
 	var regions []*internal.Region
 	for _, g := range data.GoroutineList {
 		evs := data.GoroutineEvents[g]
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"log"
 	"strings"
-
-	"github.com/rhysh/go-tracing-toolbox/internal/_vendor/trace"
 )
 
 // A Region is a contiguous series of Events on a single goroutine to accomplish
@@ -13,24 +11,37 @@ import (
 type Region struct {
 	Kind   string
 	Flags  int64
-	Events []*trace.Event
+	Events []*Event
+
+	// Task is the id of the runtime/trace user task that this Region
+	// describes, or 0.
+	Task uint64
+	// ParentTask is the id of the user task that this Region does work for,
+	// or 0. The RegionConnector uses it to link the Region to the Region of
+	// that task, even when they're on different goroutines.
+	ParentTask uint64
 }
 
 const (
 	RegionFlagShared = 0x1
+	RegionFlagRoot   = 0x2
 )
 
 // Shared returns whether the Region represents work that will be shared with
 // unrelated goroutines.
 func (r *Region) Shared() bool { return (r.Flags & 0x1) == 0x1 }
 
+// Root returns whether the Region begins a new and independent unit of work,
+// regardless of the goroutine's prior reason for running.
+func (r *Region) Root() bool { return (r.Flags & 0x2) == 0x2 }
+
 // A RegionStack is an immutable linked list of Regions and inbound
 // communication events that explain why the program is currently doing a unit
 // of work.
 type RegionStack struct {
 	// Start marks when this goroutine began the current explanation for its
 	// work.
-	Start *trace.Event
+	Start *Event
 	// Local is a Region on this goroutine that explains its current work. If
 	// this goroutine is working on behalf of another and does not have an
 	// annotation for its own work, this field will be nil.
@@ -59,17 +70,17 @@ type Task struct {
 	Children []*Task
 }
 
-type TrackerState func(*trace.Event) TrackerState
+type TrackerState func(*Event) TrackerState
 
 type GeneralTracker struct {
 	// Activate returns whether the event marks the start of a new Region.
-	Activate func(ev *trace.Event) bool
+	Activate func(ev *Event) bool
 	// Keepalive returns whether the event indicates the Region is still active.
-	Keepalive func(ev *trace.Event) bool
+	Keepalive func(ev *Event) bool
 	// Critical returns whether the event is a critical part of the Region
 	// lifecycle. Events that are not critical to a Region's lifecycle are
 	// trimmed from the end.
-	Critical func(ev *trace.Event) bool
+	Critical func(ev *Event) bool
 	// Reactivate controls whether the GeneralTracker finds the largest or
 	// smallest possible Regions. When true, the GeneralTracker will try to
 	// start a new Region for any Event that matches the Activate function. When
@@ -87,16 +98,16 @@ type GeneralTracker struct {
 	Verbose bool
 
 	// Flush is called with the Events that make up a single Region.
-	Flush func([]*trace.Event)
+	Flush func([]*Event)
 
 	trimFrom int
-	queue    []*trace.Event
+	queue    []*Event
 }
 
 // flushEvent is a sentinel for the goroutine having no more Events.
-var flushEvent = new(trace.Event)
+var flushEvent = new(Event)
 
-func (t *GeneralTracker) Process(evs []*trace.Event) {
+func (t *GeneralTracker) Process(evs []*Event) {
 	state := t.idle
 	for _, ev := range evs {
 		state = state(ev)
@@ -110,7 +121,7 @@ func (t *GeneralTracker) log(format string, v ...interface{}) {
 	}
 }
 
-func (t *GeneralTracker) idle(ev *trace.Event) TrackerState {
+func (t *GeneralTracker) idle(ev *Event) TrackerState {
 	if ev == flushEvent {
 		return nil
 	}
@@ -129,7 +140,7 @@ func (t *GeneralTracker) idle(ev *trace.Event) TrackerState {
 }
 
 // active processes an Event as a continuation of an open region.
-func (t *GeneralTracker) active(ev *trace.Event) TrackerState {
+func (t *GeneralTracker) active(ev *Event) TrackerState {
 	done := func() {
 		if fn := t.Flush; fn != nil {
 			if t.Critical == nil {
@@ -270,3 +281,12 @@ func (t *GeneralTracker) active(ev *trace.Event) TrackerState {
 //
 // The other defense we have against incorrectly applying "reasons for being" is
 // to refuse to overwrite a Region that is currently active on the goroutine.
+//
+// Some goroutines serve many units of work over their lifetimes, and carry
+// whatever "reason for being" they last received from one to the next. An
+// HTTP/2 connection's serve loop is woken by a handler goroutine when it has a
+// response to write, and then reads the next request's headers from the
+// network. The handler for that next request is not a consequence of the
+// previous response. The Region.Root method reports whether the Region starts
+// a new unit of work, discarding the goroutine's existing "reason for being"
+// rather than adding to it.
@@ -0,0 +1,218 @@
+package viz
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+)
+
+// A chromeEvent is an entry in the Chrome Trace Event format, as described in
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+// and understood by ui.perfetto.dev and chrome://tracing. Times are in
+// microseconds.
+type chromeEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Dur  *float64               `json:"dur,omitempty"`
+	Pid  int                    `json:"pid"`
+	Tid  uint64                 `json:"tid"`
+	ID   int                    `json:"id,omitempty"`
+	Bp   string                 `json:"bp,omitempty"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+type chromeTrace struct {
+	TraceEvents     []*chromeEvent    `json:"traceEvents"`
+	DisplayTimeUnit string            `json:"displayTimeUnit"`
+	OtherData       map[string]string `json:"otherData,omitempty"`
+}
+
+// chromePid is the process id for all events. The Spans all come from a single
+// Go program, and each goroutine gets its own track (or "thread").
+const chromePid = 1
+
+// Chrome converts trees of Spans into Chrome Trace Event format JSON, for
+// zooming and panning through them in ui.perfetto.dev or chrome://tracing.
+//
+// Each goroutine gets its own track. Each Span is a slice on its goroutine's
+// track, with the times when it was running, assisting, or waiting as slices
+// nested within it. The Spans that a Span caused, usually on other
+// goroutines, are connected to it with flow arrows.
+//
+// Times are relative to the start of the earliest Span, to keep them precise
+// in the format's floating point microseconds. The "startNs" entry of the
+// metadata holds that Span's start time in the execution trace.
+func Chrome(roots []*cluster.Span) ([]byte, error) {
+	var t0 int64
+	for i, root := range roots {
+		if i == 0 || root.StartNs < t0 {
+			t0 = root.StartNs
+		}
+	}
+	ts := func(ns int64) float64 { return float64(ns-t0) / 1e3 }
+
+	var evs []*chromeEvent
+	slice := func(name, cat string, g uint64, startNs, lengthNs int64, args map[string]interface{}) {
+		dur := float64(lengthNs) / 1e3
+		evs = append(evs, &chromeEvent{
+			Name: name,
+			Cat:  cat,
+			Ph:   "X",
+			Ts:   ts(startNs),
+			Dur:  &dur,
+			Pid:  chromePid,
+			Tid:  g,
+			Args: args,
+		})
+	}
+
+	goroutines := make(map[uint64]bool)
+	var flows int
+	var visitErr error
+	for _, root := range roots {
+		cluster.Visit(root, func(span *cluster.Span) {
+			if visitErr != nil {
+				return
+			}
+			goroutines[span.G] = true
+			slice(span.Kind, "span", span.G, span.StartNs, span.LengthNs, map[string]interface{}{
+				"g":    span.G,
+				"root": root.Kind,
+			})
+
+			ranges, err := cluster.Running(span)
+			if err != nil {
+				visitErr = fmt.Errorf("span %q on g%d at %d: %w", span.Kind, span.G, span.StartNs, err)
+				return
+			}
+			// The "cpu" wait before a Span's GoStart happens before the Span
+			// begins. Keep the segments within the Span, so they nest. And
+			// leave room for the Spans it caused on the same goroutine, which
+			// describe their own segments.
+			window := [2]int64{0, span.LengthNs}
+			var holes [][2]int64
+			for _, child := range span.Caused {
+				if child.G == span.G {
+					start := child.StartNs - span.StartNs
+					holes = append(holes, [2]int64{start, start + child.LengthNs})
+				}
+			}
+			segments := func(name string, tss [][2]int64) {
+				for _, v := range tss {
+					if v[0] < window[0] {
+						v[0] = window[0]
+					}
+					if v[1] > window[1] {
+						v[1] = window[1]
+					}
+					for _, v := range carve(v, holes) {
+						slice(name, "state", span.G, span.StartNs+v[0], v[1]-v[0], nil)
+					}
+				}
+			}
+			segments("running", ranges.Running)
+			for _, reason := range sortedKeys(ranges.Assisting) {
+				segments("assist "+reason, ranges.Assisting[reason])
+			}
+			for _, reason := range sortedKeys(ranges.Waiting) {
+				segments("wait "+reason, ranges.Waiting[reason])
+			}
+
+			for _, child := range span.Caused {
+				if child.G == span.G {
+					// Nesting on the track shows the connection.
+					continue
+				}
+				// A flow starts from the slice that encloses it, so keep it
+				// within the cause's Span.
+				from := child.StartNs
+				if end := span.StartNs + span.LengthNs; from > end {
+					from = end
+				}
+				if from < span.StartNs {
+					from = span.StartNs
+				}
+				flows++
+				evs = append(evs,
+					&chromeEvent{Name: "caused", Cat: "caused", Ph: "s", Ts: ts(from), Pid: chromePid, Tid: span.G, ID: flows},
+					&chromeEvent{Name: "caused", Cat: "caused", Ph: "f", Bp: "e", Ts: ts(child.StartNs), Pid: chromePid, Tid: child.G, ID: flows},
+				)
+			}
+		})
+		if visitErr != nil {
+			return nil, visitErr
+		}
+	}
+
+	// Slices that start at the same time on a track nest according to the
+	// order they appear, so put the longer (enclosing) one first.
+	sort.SliceStable(evs, func(i, j int) bool {
+		ei, ej := evs[i], evs[j]
+		if ei.Ts != ej.Ts {
+			return ei.Ts < ej.Ts
+		}
+		if ei.Dur != nil && ej.Dur != nil {
+			return *ei.Dur > *ej.Dur
+		}
+		return false
+	})
+
+	var gs []uint64
+	for g := range goroutines {
+		gs = append(gs, g)
+	}
+	sort.Slice(gs, func(i, j int) bool { return gs[i] < gs[j] })
+	meta := []*chromeEvent{{
+		Name: "process_name", Ph: "M", Pid: chromePid,
+		Args: map[string]interface{}{"name": "go"},
+	}}
+	for _, g := range gs {
+		meta = append(meta, &chromeEvent{
+			Name: "thread_name", Ph: "M", Pid: chromePid, Tid: g,
+			Args: map[string]interface{}{"name": fmt.Sprintf("g%d", g)},
+		})
+	}
+
+	return json.Marshal(&chromeTrace{
+		TraceEvents:     append(meta, evs...),
+		DisplayTimeUnit: "ns",
+		OtherData:       map[string]string{"startNs": fmt.Sprint(t0)},
+	})
+}
+
+func sortedKeys(m map[string][][2]int64) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// carve returns the parts of the range v that aren't in any of the holes.
+func carve(v [2]int64, holes [][2]int64) [][2]int64 {
+	out := [][2]int64{v}
+	for _, hole := range holes {
+		var next [][2]int64
+		for _, w := range out {
+			if hole[0] > w[0] {
+				next = append(next, [2]int64{w[0], min(w[1], hole[0])})
+			}
+			if hole[1] < w[1] {
+				next = append(next, [2]int64{max(w[0], hole[1]), w[1]})
+			}
+		}
+		out = next
+	}
+	var keep [][2]int64
+	for _, w := range out {
+		if w[0] < w[1] {
+			keep = append(keep, w)
+		}
+	}
+	return keep
+}
@@ -0,0 +1,126 @@
+package viz_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rhysh/go-tracing-toolbox/internal/cluster"
+	"github.com/rhysh/go-tracing-toolbox/internal/viz"
+)
+
+func TestChrome(t *testing.T) {
+	root := &cluster.Span{
+		G:         10,
+		Kind:      "server/http",
+		StartNs:   5000,
+		LengthNs:  100,
+		StartRun:  []int64{0, 80},
+		StartWait: map[string][]int64{"net": {10}},
+		Caused: []*cluster.Span{{
+			G:         10,
+			Kind:      "client/http_roundtrip",
+			StartNs:   5020,
+			LengthNs:  50,
+			StartRun:  []int64{0},
+			StartWait: map[string][]int64{"select": {5}},
+		}, {
+			G:         11,
+			Kind:      "client/http_write",
+			StartNs:   5030,
+			LengthNs:  10,
+			StartRun:  []int64{0},
+			StartWait: map[string][]int64{"cpu": {-4}},
+		}},
+	}
+
+	buf, err := viz.Chrome([]*cluster.Span{root})
+	if err != nil {
+		t.Fatalf("Chrome; err = %v", err)
+	}
+
+	var trace struct {
+		TraceEvents []struct {
+			Name string
+			Ph   string
+			Ts   float64
+			Dur  float64
+			Tid  uint64
+			ID   int
+		}
+		OtherData map[string]string
+	}
+	if err := json.Unmarshal(buf, &trace); err != nil {
+		t.Fatalf("json.Unmarshal; err = %v", err)
+	}
+
+	if have, want := trace.OtherData["startNs"], "5000"; have != want {
+		t.Errorf("startNs; %q != %q", have, want)
+	}
+
+	type slice struct {
+		name       string
+		g          uint64
+		start, end int64
+	}
+	var slices []slice
+	var threads []uint64
+	flows := make(map[int][]uint64)
+	for _, ev := range trace.TraceEvents {
+		switch ev.Ph {
+		case "X":
+			slices = append(slices, slice{ev.Name, ev.Tid, int64(ev.Ts * 1e3), int64((ev.Ts + ev.Dur) * 1e3)})
+		case "M":
+			if ev.Name == "thread_name" {
+				threads = append(threads, ev.Tid)
+			}
+		case "s", "f":
+			flows[ev.ID] = append(flows[ev.ID], ev.Tid)
+		}
+	}
+
+	// The parent's own segments leave room for the child Span on the same
+	// goroutine, and the child Span's segments stay within it (dropping the
+	// "cpu" wait from before the child started).
+	if have, want := slices, []slice{
+		{"server/http", 10, 0, 100},
+		{"running", 10, 0, 10},
+		{"wait net", 10, 10, 20},
+		{"client/http_roundtrip", 10, 20, 70},
+		{"running", 10, 20, 25},
+		{"wait select", 10, 25, 70},
+		{"client/http_write", 11, 30, 40},
+		{"running", 11, 30, 40},
+		{"wait net", 10, 70, 80},
+		{"running", 10, 80, 100},
+	}; !reflect.DeepEqual(have, want) {
+		t.Errorf("slices;\n%v\n!=\n%v", have, want)
+	}
+	if have, want := threads, []uint64{10, 11}; !reflect.DeepEqual(have, want) {
+		t.Errorf("threads; %v != %v", have, want)
+	}
+	// Only the child on another goroutine gets a flow arrow.
+	if have, want := flows, map[int][]uint64{1: {10, 11}}; !reflect.DeepEqual(have, want) {
+		t.Errorf("flows; %v != %v", have, want)
+	}
+}
+
+func TestChromeRunningError(t *testing.T) {
+	root := &cluster.Span{
+		G:         1,
+		Kind:      "server/http",
+		StartNs:   5000,
+		LengthNs:  100,
+		StartRun:  []int64{0},
+		StartWait: map[string][]int64{"": {10}},
+	}
+
+	_, err := viz.Chrome([]*cluster.Span{root})
+	if err == nil {
+		t.Fatalf("Chrome with blank wait reason; err = nil")
+	}
+	if want := "server/http"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Chrome; err = %q, expected it to name %q", err, want)
+	}
+}
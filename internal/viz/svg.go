@@ -27,6 +27,9 @@ const (
 	imgBarFillNetwork = "yellow"
 	imgBarFillSyscall = "darkgreen"
 	imgBarFillBlocked = "violet"
+	imgBarFillDBPool  = "orange"
+	imgCriticalFill   = "red"
+	imgCriticalHeight = 1
 )
 
 type Job struct {
@@ -110,6 +113,7 @@ func Render(spans []*cluster.TreeSummary, expand bool) []byte {
 			label := fmt.Sprintf("g=%d @%0.6fms / %0.6fms / on CPU %0.6fms %s", root.Flat.G,
 				float64(root.Flat.StartNs)/1e6, float64(root.LengthNs)/1e6, float64(root.TotalRunNs)/1e6, root.Flat.Kind)
 			renderSpan(img, r, minTs, root.Flat, label)
+			renderCritical(img, r, minTs, root.Flat, root.Root.CriticalPath, true)
 			r.y += imgBarHeight
 			continue
 		}
@@ -122,6 +126,7 @@ func Render(spans []*cluster.TreeSummary, expand bool) []byte {
 			}
 
 			renderSpan(img, r, minTs, span, label)
+			renderCritical(img, r, minTs, span, root.Root.CriticalPath, false)
 
 			r.y += imgBarHeight
 		})
@@ -140,6 +145,7 @@ func Render(spans []*cluster.TreeSummary, expand bool) []byte {
 			label := fmt.Sprintf("g=%d @%0.6fms / %0.6fms / on CPU %0.6fms %s", root.Flat.G,
 				float64(root.Flat.StartNs)/1e6, float64(root.LengthNs)/1e6, float64(root.TotalRunNs)/1e6, root.Flat.Kind)
 			renderSpan(img, r, root.Flat.StartNs, root.Flat, label)
+			renderCritical(img, r, root.Flat.StartNs, root.Flat, root.Root.CriticalPath, true)
 			r.y += imgBarHeight
 			continue
 		}
@@ -152,6 +158,7 @@ func Render(spans []*cluster.TreeSummary, expand bool) []byte {
 			}
 
 			renderSpan(img, r, root.Root.StartNs, span, label)
+			renderCritical(img, r, root.Root.StartNs, span, root.Root.CriticalPath, false)
 
 			r.y += imgBarHeight
 		})
@@ -264,12 +271,15 @@ func renderSpan(img *svg.SVG, r *Ribbon, x0 int64, span *cluster.Span, label str
 			"block": {fmt.Sprintf("fill=%q", imgBarFillBlocked)},
 			"cond":  {fmt.Sprintf("fill=%q", imgBarFillBlocked)},
 			"sync":  {fmt.Sprintf("fill=%q", imgBarFillBlocked)},
+			"sleep": {fmt.Sprintf("fill=%q", imgBarFillBlocked)},
 
 			"recv":   {fmt.Sprintf("fill=%q", imgBarFillBlocked)},
 			"select": {fmt.Sprintf("fill=%q", imgBarFillBlocked)},
 			"send":   {fmt.Sprintf("fill=%q", imgBarFillBlocked)},
 
 			"syscall": {fmt.Sprintf("fill=%q", imgBarFillSyscall)},
+
+			"dbpool": {fmt.Sprintf("fill=%q", imgBarFillDBPool)},
 		}[reason]
 		if !ok {
 			style = []string{fmt.Sprintf("fill=%q", imgBarFillBug)}
@@ -295,15 +305,41 @@ func renderSpan(img *svg.SVG, r *Ribbon, x0 int64, span *cluster.Span, label str
 	}
 }
 
+// renderCritical underlines the parts of the span's bar that are on the
+// critical path. For a summary's flattened span, that's every Segment;
+// otherwise, it's the Segments on the span's own goroutine.
+func renderCritical(img *svg.SVG, r *Ribbon, x0 int64, span *cluster.Span, path []*cluster.Segment, flat bool) {
+	for _, seg := range path {
+		if !flat && seg.G != span.G {
+			continue
+		}
+		start := max(seg.StartNs, span.StartNs)
+		end := min(seg.StartNs+seg.LengthNs, span.StartNs+span.LengthNs)
+		if start >= end {
+			continue
+		}
+		renderBar(img, r, x0, &Bar{
+			offsetNs: start - x0,
+			lengthNs: end - start,
+			dy:       imgBarHeight - imgCriticalHeight,
+			height:   imgCriticalHeight,
+			style:    []string{fmt.Sprintf("fill=%q", imgCriticalFill)},
+		})
+	}
+}
+
 func renderBar(img *svg.SVG, r *Ribbon, x0 int64, b *Bar) (x int) {
 	x = int(int64(r.widthPx) * b.offsetNs / r.widthNs)
 	w := int(int64(r.widthPx)*(b.offsetNs+b.lengthNs)/r.widthNs) - x
 	h := imgBarHeight
+	if b.height > 0 {
+		h = b.height
+	}
 	if w < b.minWidth {
 		w = b.minWidth
 	}
 	if w > 0 && x < r.widthPx {
-		img.Rect(x, r.y, w, h, b.style...)
+		img.Rect(x, r.y+b.dy, w, h, b.style...)
 	}
 	return x
 }
@@ -319,4 +355,9 @@ type Bar struct {
 	lengthNs int64
 	minWidth int
 	style    []string
+
+	// dy and height place the Bar within its Ribbon's row, when it shouldn't
+	// fill the whole row.
+	dy     int
+	height int
 }
@@ -0,0 +1,93 @@
+// Command prog writes a small execution trace to stdout, covering the
+// scheduler events that the trace converters need to handle.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"runtime"
+	"runtime/trace"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var sink []byte
+
+func main() {
+	runtime.GOMAXPROCS(2)
+
+	// These goroutines exist before the trace begins: one is running, and the
+	// other is waiting on a channel.
+	var stop atomic.Bool
+	go spin(&stop)
+	wake := make(chan int)
+	go wait(wake)
+	time.Sleep(10 * time.Millisecond)
+
+	if err := trace.Start(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, task := trace.NewTask(context.Background(), "events")
+	trace.Log(ctx, "phase", "start")
+
+	// Wake the goroutine that was waiting before the trace began.
+	wake <- 1
+
+	// A chain of wakeups across three goroutines, two of them new.
+	a, b, c := make(chan int), make(chan int), make(chan int)
+	go relay(a, b)
+	go relay(b, c)
+	a <- 1
+	<-c
+
+	time.Sleep(time.Millisecond)
+
+	// A short syscall, and one long enough to lose the P.
+	if fd, err := syscall.Open("/dev/null", syscall.O_WRONLY, 0); err == nil {
+		syscall.Write(fd, []byte("x"))
+		syscall.Close(fd)
+	}
+	ts := syscall.NsecToTimespec((50 * time.Millisecond).Nanoseconds())
+	syscall.Nanosleep(&ts, nil)
+
+	// More work than Ps, so the scheduler preempts someone.
+	done := make(chan int)
+	go hog(30*time.Millisecond, done)
+	go hog(30*time.Millisecond, done)
+	<-done
+	<-done
+
+	// Sweeping after a GC shows up as ranges with attributes.
+	runtime.GC()
+	for range 1000 {
+		sink = make([]byte, 4096)
+	}
+
+	trace.Log(ctx, "phase", "end")
+	task.End()
+	stop.Store(true)
+	trace.Stop()
+}
+
+func spin(stop *atomic.Bool) {
+	for !stop.Load() {
+	}
+}
+
+func wait(wake chan int) {
+	<-wake
+}
+
+func relay(in, out chan int) {
+	v := <-in
+	out <- v + 1
+}
+
+func hog(d time.Duration, done chan int) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+	done <- 1
+}